@@ -3,8 +3,23 @@ package application_types
 import "github.com/golang-jwt/jwt/v4"
 
 type AccessToken struct {
+	UserID    uint   `json:"sub"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	// OrganizationID is the organization the token acts in. It is zero for
+	// platform administrators, who are not members of any organization.
+	OrganizationID uint `json:"org,omitempty"`
+	// BranchID limits billing data to a branch of the organization. It is zero
+	// for users allowed in every branch who did not pick one.
+	BranchID uint   `json:"branch,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Actor is the user acting on behalf of the subject of an impersonation
+// token, following the "act" claim of RFC 8693.
+type Actor struct {
 	UserID uint   `json:"sub"`
 	Name   string `json:"name"`
-	Role   string `json:"role"`
-	jwt.RegisteredClaims
 }
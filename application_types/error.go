@@ -7,6 +7,8 @@ type ApplicationError struct {
 	isSuccess   bool
 	httpMessage string
 	err         error
+	headers     map[string]string
+	fields      map[string][]string
 }
 
 func NewApplicationError(isSuccess bool, httpStatus int, httpMessage string, err error) *ApplicationError {
@@ -22,9 +24,36 @@ func (appErr *ApplicationError) GetErrorMessage() string {
 	return appErr.err.Error()
 }
 
+// SetHeader adds a header, e.g. Retry-After, to the HTTP response of the error.
+func (appErr *ApplicationError) SetHeader(key, value string) *ApplicationError {
+	if appErr.headers == nil {
+		appErr.headers = map[string]string{}
+	}
+	appErr.headers[key] = value
+	return appErr
+}
+
+// AddFieldErrors explains which rules a request field failed. They are written
+// under result.fields of the HTTP response.
+func (appErr *ApplicationError) AddFieldErrors(field string, messages ...string) *ApplicationError {
+	if appErr.fields == nil {
+		appErr.fields = map[string][]string{}
+	}
+	appErr.fields[field] = append(appErr.fields[field], messages...)
+	return appErr
+}
+
+func (appErr *ApplicationError) GetFieldErrors() map[string][]string {
+	return appErr.fields
+}
+
 func (appErr *ApplicationError) WriteHTTPResponse(c *gin.Context) {
 	responseBody := gin.H{}
 
+	for key, value := range appErr.headers {
+		c.Header(key, value)
+	}
+
 	if appErr.isSuccess {
 		responseBody["status"] = "success"
 	} else {
@@ -32,7 +61,11 @@ func (appErr *ApplicationError) WriteHTTPResponse(c *gin.Context) {
 
 	}
 	responseBody["message"] = appErr.httpMessage
-	responseBody["result"] = gin.H{"error": appErr.GetErrorMessage()}
+	result := gin.H{"error": appErr.GetErrorMessage()}
+	if len(appErr.fields) > 0 {
+		result["fields"] = appErr.fields
+	}
+	responseBody["result"] = result
 
 	c.JSON(appErr.httpStatus, responseBody)
 }
@@ -40,3 +73,7 @@ func (appErr *ApplicationError) WriteHTTPResponse(c *gin.Context) {
 func (appErr *ApplicationError) GetError() error {
 	return appErr.err
 }
+
+func (appErr *ApplicationError) GetHTTPStatusCode() int {
+	return appErr.httpStatus
+}
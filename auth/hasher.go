@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"treeforms_billing/logger"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
+)
+
+// Hasher hashes secrets into self describing strings, so that hashes made with
+// older algorithms or parameters keep verifying and can be detected for rehashing.
+type Hasher interface {
+	Hash(plain string) (string, error)
+	Verify(hash, plain string) (bool, error)
+	// Owns reports whether the hash was made by this algorithm.
+	Owns(hash string) bool
+	// NeedsRehash reports whether an owned hash uses outdated parameters.
+	NeedsRehash(hash string) bool
+}
+
+var errUnknownHash = errors.New("unknown password hash format")
+
+const (
+	defaultArgon2Memory      = 19 * 1024
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+)
+
+// GetHasher returns the hasher configured by PASSWORD_HASH_ALGORITHM, which is
+// argon2id unless set to bcrypt:
+//
+//	ARGON2_MEMORY_KIB      default 19456, at least 8 per thread
+//	ARGON2_ITERATIONS      default 2, at least 1
+//	ARGON2_PARALLELISM     default 1, between 1 and 255
+//	BCRYPT_COST            default 10, between 4 and 31
+//
+// An invalid configuration falls back to the defaults, CheckHasher reports it
+// at startup.
+func GetHasher() Hasher {
+	hasher, err := configuredHasher()
+	if err != nil {
+		logger.HighlightedDanger("Invalid password hasher configuration, using the defaults. Message: " + err.Error())
+		return newArgon2idHasher(defaultArgon2Memory, defaultArgon2Iterations, defaultArgon2Parallelism)
+	}
+	return hasher
+}
+
+// CheckHasher validates the password hasher configuration.
+func CheckHasher() error {
+	_, err := configuredHasher()
+	return err
+}
+
+func configuredHasher() (Hasher, error) {
+	if strings.EqualFold(os.Getenv("PASSWORD_HASH_ALGORITHM"), HashAlgorithmBcrypt) {
+		cost := envInt("BCRYPT_COST", bcrypt.DefaultCost)
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+		}
+		return bcryptHasher{cost: cost}, nil
+	}
+
+	memory := envInt("ARGON2_MEMORY_KIB", defaultArgon2Memory)
+	iterations := envInt("ARGON2_ITERATIONS", defaultArgon2Iterations)
+	parallelism := envInt("ARGON2_PARALLELISM", defaultArgon2Parallelism)
+	if parallelism < 1 || parallelism > math.MaxUint8 {
+		return nil, fmt.Errorf("ARGON2_PARALLELISM must be between 1 and %d, got %d", math.MaxUint8, parallelism)
+	}
+	if memory < 8*parallelism || int64(memory) > math.MaxUint32 {
+		return nil, fmt.Errorf("ARGON2_MEMORY_KIB must be between %d and %d, got %d", 8*parallelism, uint32(math.MaxUint32), memory)
+	}
+	if iterations < 1 || int64(iterations) > math.MaxUint32 {
+		return nil, fmt.Errorf("ARGON2_ITERATIONS must be at least 1, got %d", iterations)
+	}
+
+	return newArgon2idHasher(uint32(memory), uint32(iterations), uint8(parallelism)), nil
+}
+
+func newArgon2idHasher(memory, iterations uint32, parallelism uint8) argon2idHasher {
+	return argon2idHasher{
+		memory:      memory,
+		iterations:  iterations,
+		parallelism: parallelism,
+		saltLength:  16,
+		keyLength:   32,
+	}
+}
+
+// HashSecret hashes a password or a similar secret with the configured hasher.
+func HashSecret(plain string) (string, error) {
+	return GetHasher().Hash(plain)
+}
+
+// VerifySecret checks the secret against a hash made by any supported hasher.
+func VerifySecret(hash, plain string) (bool, error) {
+	for _, hasher := range knownHashers() {
+		if hasher.Owns(hash) {
+			return hasher.Verify(hash, plain)
+		}
+	}
+	return false, errUnknownHash
+}
+
+// SecretNeedsRehash reports whether the hash was not made by the configured
+// hasher with its current parameters.
+func SecretNeedsRehash(hash string) bool {
+	hasher := GetHasher()
+	return !hasher.Owns(hash) || hasher.NeedsRehash(hash)
+}
+
+func knownHashers() []Hasher {
+	return []Hasher{argon2idHasher{}, bcryptHasher{}}
+}
+
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+// Hash encodes the hash in the PHC string format,
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+func (h argon2idHasher) Hash(plain string) (string, error) {
+	salt := make([]byte, h.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error occurred while generating salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(plain), salt, h.iterations, h.memory, h.parallelism, h.keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h argon2idHasher) Verify(hash, plain string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(plain), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (h argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.memory || params.iterations != h.iterations || params.parallelism != h.parallelism ||
+		uint32(len(key)) != h.keyLength
+}
+
+func decodeArgon2id(hash string) (argon2idHasher, []byte, []byte, error) {
+	var params argon2idHasher
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashAlgorithmArgon2id {
+		return params, nil, nil, errUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2 key: %w", err)
+	}
+
+	return params, salt, key, nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h bcryptHasher) Hash(plain string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("error occurred while hashing password: %w", err)
+	}
+	return string(hashed), nil
+}
+
+func (h bcryptHasher) Verify(hash, plain string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h bcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+)
+
+const recoveryCodeCount = 10
+
+type mfaSecret struct {
+	id           uint
+	userID       uint
+	secret       string
+	confirmed    bool
+	lastUsedStep int64
+}
+
+// NewMFASecret replaces any existing secret of the user with a new unconfirmed
+// one. The secret is stored encrypted since it has to be read back to verify codes.
+func NewMFASecret(userID uint) (*mfaSecret, error) {
+	db := db.Get()
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptMFASecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var id uint
+	upsertQuery := `INSERT INTO mfa_secrets (secret, user_id, confirmed_at, last_used_step) VALUES (?, ?, NULL, 0)
+	ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, confirmed_at = NULL, last_used_step = 0 RETURNING id;`
+
+	if err := db.Raw(upsertQuery, encrypted, userID).Scan(&id).Error; err != nil {
+		logger.HighlightedDanger("MFA secret creation failed. Message: " + err.Error())
+		return nil, err
+	}
+
+	return &mfaSecret{id: id, userID: userID, secret: secret}, nil
+}
+
+// GetMFASecretByUserID returns nil without an error when the user has no secret.
+func GetMFASecretByUserID(userID uint) (*mfaSecret, error) {
+	db := db.Get()
+	selectQuery := `SELECT id, secret, user_id, confirmed_at IS NOT NULL, last_used_step FROM mfa_secrets WHERE user_id = ?`
+
+	rows, err := db.Raw(selectQuery, userID).Rows()
+	if err != nil {
+		logger.HighlightedDanger("Query execution failed for getting mfa secret using userid. Message: " + err.Error())
+		return nil, fmt.Errorf("query execution failed for getting mfa secret using userid: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	m := &mfaSecret{}
+	var encrypted string
+	if err := rows.Scan(&m.id, &encrypted, &m.userID, &m.confirmed, &m.lastUsedStep); err != nil {
+		logger.HighlightedDanger("Scan failed getting mfa secret using userid. Message: " + err.Error())
+		return nil, fmt.Errorf("scan failed getting mfa secret using userid: %w", err)
+	}
+
+	if m.secret, err = decryptMFASecret(encrypted); err != nil {
+		logger.HighlightedDanger("Unable to decrypt mfa secret. Message: " + err.Error())
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *mfaSecret) GetSecret() string {
+	return m.secret
+}
+
+func (m *mfaSecret) IsConfirmed() bool {
+	return m.confirmed
+}
+
+// VerifyCode validates a TOTP code and records its time step so that the same
+// code cannot be used twice.
+func (m *mfaSecret) VerifyCode(code string) bool {
+	step, ok := ValidateTOTP(m.secret, code, time.Now())
+	if !ok || step <= m.lastUsedStep {
+		return false
+	}
+
+	res := db.Get().Exec(`UPDATE mfa_secrets SET last_used_step = ? WHERE id = ? AND last_used_step < ?`, step, m.id, step)
+	if res.Error != nil {
+		logger.HighlightedDanger("Unable to record mfa code usage. Message: " + res.Error.Error())
+		return false
+	}
+	if res.RowsAffected == 0 {
+		return false
+	}
+
+	m.lastUsedStep = step
+	return true
+}
+
+func (m *mfaSecret) Confirm() error {
+	if err := db.Get().Exec(`UPDATE mfa_secrets SET confirmed_at = NOW() WHERE id = ?`, m.id).Error; err != nil {
+		logger.HighlightedDanger("Confirming mfa secret failed for the user id " + strconv.FormatUint(uint64(m.userID), 10) + ". Message: " + err.Error())
+		return err
+	}
+
+	m.confirmed = true
+	return nil
+}
+
+// NewRecoveryCodes replaces the recovery codes of the user and returns the new
+// plain codes. Only their hashes are stored. The codes carry 80 random bits, so
+// like the other opaque tokens a fast hash is enough to store them.
+func NewRecoveryCodes(userID uint) ([]string, error) {
+	db := db.Get()
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		bytes := make([]byte, 10)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, fmt.Errorf("error occurred while generating recovery code: %w", err)
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(bytes))
+		code = code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashOpaqueToken(code))
+	}
+
+	tx := db.Begin()
+	if err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID).Error; err != nil {
+		tx.Rollback()
+		logger.HighlightedDanger("Deleting old recovery codes failed. Message: " + err.Error())
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		if err := tx.Exec(`INSERT INTO mfa_recovery_codes (hash, user_id) VALUES (?, ?)`, hash, userID).Error; err != nil {
+			tx.Rollback()
+			logger.HighlightedDanger("Recovery code creation failed. Message: " + err.Error())
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logger.HighlightedDanger("Recovery code creation failed. Message: " + err.Error())
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode consumes a matching unused recovery code of the user.
+func UseRecoveryCode(userID uint, code string) (bool, error) {
+	db := db.Get()
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	res := db.Exec(`UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = ? AND hash = ? AND used_at IS NULL`, userID, HashOpaqueToken(code))
+	if res.Error != nil {
+		logger.HighlightedDanger("Unable to consume recovery code. Message: " + res.Error.Error())
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// DeleteMFAByUserID removes the secret and the recovery codes of the user.
+func DeleteMFAByUserID(userID uint) error {
+	db := db.Get()
+	tx := db.Begin()
+	if err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec(`DELETE FROM mfa_secrets WHERE user_id = ?`, userID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	logger.Warning("Deleted mfa for the user id " + strconv.FormatUint(uint64(userID), 10))
+	return nil
+}
+
+var (
+	mfaKeyMu sync.RWMutex
+	mfaKey   []byte
+)
+
+// LoadMFAEncryptionKey reads MFA_ENCRYPTION_KEY, a base64 encoded 32 byte key.
+// It is required and never derived from another secret, so that TOTP secrets
+// stay encrypted and readable when the jwt keys are rotated.
+func LoadMFAEncryptionKey() error {
+	raw := strings.TrimSpace(os.Getenv("MFA_ENCRYPTION_KEY"))
+	if raw == "" {
+		return fmt.Errorf("MFA_ENCRYPTION_KEY is not set")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return fmt.Errorf("MFA_ENCRYPTION_KEY is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("MFA_ENCRYPTION_KEY must decode to 32 bytes, got %d", len(key))
+	}
+
+	mfaKeyMu.Lock()
+	defer mfaKeyMu.Unlock()
+	mfaKey = key
+	return nil
+}
+
+func mfaEncryptionKey() ([]byte, error) {
+	mfaKeyMu.RLock()
+	defer mfaKeyMu.RUnlock()
+	if mfaKey == nil {
+		return nil, fmt.Errorf("the mfa encryption key is not loaded")
+	}
+	return mfaKey, nil
+}
+
+func encryptMFASecret(secret string) (string, error) {
+	key, err := mfaEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error occurred while encrypting mfa secret: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptMFASecret(encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := mfaEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid mfa secret ciphertext")
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt mfa secret: %w", err)
+	}
+	return string(plain), nil
+}
@@ -6,7 +6,7 @@ import (
 	"treeforms_billing/db"
 	"treeforms_billing/logger"
 
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type password struct {
@@ -15,17 +15,40 @@ type password struct {
 	userID uint
 }
 
+// NewPassword enforces the password policy and sets the password of the user,
+// replacing the current one.
 func NewPassword(userID uint, plainPassword string) (*password, error) {
-	db := db.Get()
-	hashed, err := bcrypt.GenerateFromPassword([]byte(plainPassword), bcrypt.DefaultCost)
+	var p *password
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		var err error
+		p, err = NewPasswordWithTx(tx, userID, plainPassword)
+		return err
+	})
+	return p, err
+}
+
+// NewPasswordWithTx is NewPassword within the transaction of the caller. A
+// *PasswordPolicyError is returned when the password breaks the policy.
+func NewPasswordWithTx(tx *gorm.DB, userID uint, plainPassword string) (*password, error) {
+	if err := ValidatePassword(tx, userID, plainPassword); err != nil {
+		return nil, err
+	}
+
+	hashed, err := HashSecret(plainPassword)
 	if err != nil {
-		return nil, fmt.Errorf("error occurred while hashing password: %w", err)
+		return nil, err
 	}
 
 	var id uint
-	insertQuery := `INSERT INTO passwords (hash, user_id) VALUES (?, ?) RETURNING id;`
+	upsertQuery := `INSERT INTO passwords (hash, user_id) VALUES (?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET hash = EXCLUDED.hash RETURNING id;`
+
+	if err := tx.Raw(upsertQuery, hashed, userID).Scan(&id).Error; err != nil {
+		logger.HighlightedDanger("Password creation failed. Message: " + err.Error())
+		return nil, err
+	}
 
-	if err := db.Raw(insertQuery, string(hashed), userID).Scan(&id).Error; err != nil {
+	if err := recordPasswordHistory(tx, userID, hashed); err != nil {
 		logger.HighlightedDanger("Password creation failed. Message: " + err.Error())
 		return nil, err
 	}
@@ -33,7 +56,7 @@ func NewPassword(userID uint, plainPassword string) (*password, error) {
 	password := &password{
 		id:     id,
 		userID: userID,
-		hash:   hashed,
+		hash:   []byte(hashed),
 	}
 
 	return password, nil
@@ -48,23 +71,51 @@ func GetPasswordByUserID(userID uint) (*password, error) {
 	rows, err := db.Raw(selectQuery, userID).Rows()
 	if err != nil {
 		logger.HighlightedDanger("Query execution failed for getting password using userid. Message: " + err.Error())
-		return nil, fmt.Errorf("Query execution failed for getting password using userid. Message: " + err.Error())
+		return nil, fmt.Errorf("Query execution failed for getting password using userid. Message: %s", err.Error())
 	}
 
 	defer rows.Close()
 
-	if rows.Next() {
-		if err := rows.Scan(&p.id, &p.hash, &p.userID); err != nil {
-			logger.HighlightedDanger("Scan failed getting password using userid. Message: " + err.Error())
-			return nil, fmt.Errorf("Scan failed getting password using userid. Message: " + err.Error())
-		}
+	// Users without a password, e.g. provisioned through SSO, get nil without an error.
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	if err := rows.Scan(&p.id, &p.hash, &p.userID); err != nil {
+		logger.HighlightedDanger("Scan failed getting password using userid. Message: " + err.Error())
+		return nil, fmt.Errorf("Scan failed getting password using userid. Message: %s", err.Error())
 	}
 	return p, nil
 }
 
 func (p *password) VerifyPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(p.hash), []byte(password))
-	return err == nil
+	ok, err := VerifySecret(string(p.hash), password)
+	if err != nil {
+		logger.HighlightedDanger("Unable to verify the password of the user id " + strconv.FormatUint(uint64(p.userID), 10) + ". Message: " + err.Error())
+	}
+	return ok
+}
+
+// NeedsRehash reports whether the password was hashed with an outdated
+// algorithm or outdated parameters.
+func (p *password) NeedsRehash() bool {
+	return SecretNeedsRehash(string(p.hash))
+}
+
+// Rehash replaces the stored hash with a hash of the configured hasher. It is
+// meant to run right after VerifyPassword succeeded with the same password, so
+// the password policy is not applied again.
+func (p *password) Rehash(plainPassword string) error {
+	hashed, err := HashSecret(plainPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Get().Exec(`UPDATE passwords SET hash = ? WHERE id = ?`, hashed, p.id).Error; err != nil {
+		return fmt.Errorf("unable to store the rehashed password: %w", err)
+	}
+
+	p.hash = []byte(hashed)
+	return nil
 }
 
 func (p *password) GetUserID() uint {
@@ -77,7 +128,7 @@ func (p *password) Delete() error {
 	res := db.Exec(`DELETE FROM passwords WHERE id = ?`, p.id)
 	if res.Error != nil {
 		logger.HighlightedDanger("Deleting password failed for the user id " + strconv.FormatUint(uint64(p.userID), 10) + ". Message: " + res.Error.Error())
-		return fmt.Errorf("Deleting password failed for the user id " + strconv.FormatUint(uint64(p.userID), 10) + ". Message: " + res.Error.Error())
+		return fmt.Errorf("Deleting password failed for the user id %s. Message: %s", strconv.FormatUint(uint64(p.userID), 10), res.Error.Error())
 	}
 
 	logger.Warning("Deleting password for the user id " + strconv.FormatUint(uint64(p.userID), 10))
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"treeforms_billing/logger"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPasswordMinLength   = 10
+	defaultPasswordMaxLength   = 72 // the limit of bcrypt, kept for PASSWORD_HASH_ALGORITHM=bcrypt
+	defaultPasswordHistorySize = 5
+)
+
+// PasswordPolicy is configured through the environment:
+//
+//	PASSWORD_MIN_LENGTH            default 10
+//	PASSWORD_MAX_LENGTH            default 72
+//	PASSWORD_REQUIRE_UPPERCASE     default true
+//	PASSWORD_REQUIRE_LOWERCASE     default true
+//	PASSWORD_REQUIRE_DIGIT         default true
+//	PASSWORD_REQUIRE_SYMBOL        default false
+//	PASSWORD_HISTORY_SIZE          number of previous passwords which cannot be reused, default 5
+//	PASSWORD_BREACHED_LIST_FILE    file with one breached password per line, optional, loaded at startup
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	HistorySize      int
+	BreachedListFile string
+}
+
+// PasswordPolicyError lists every rule of the policy which the password breaks.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "Password does not meet the password policy: " + strings.Join(e.Violations, "; ")
+}
+
+func GetPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:        envInt("PASSWORD_MIN_LENGTH", defaultPasswordMinLength),
+		MaxLength:        envInt("PASSWORD_MAX_LENGTH", defaultPasswordMaxLength),
+		RequireUppercase: envBool("PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLowercase: envBool("PASSWORD_REQUIRE_LOWERCASE", true),
+		RequireDigit:     envBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:    envBool("PASSWORD_REQUIRE_SYMBOL", false),
+		HistorySize:      envInt("PASSWORD_HISTORY_SIZE", defaultPasswordHistorySize),
+		BreachedListFile: os.Getenv("PASSWORD_BREACHED_LIST_FILE"),
+	}
+}
+
+// Check validates the password against the rules which do not depend on the user.
+func (p PasswordPolicy) Check(plainPassword string) []string {
+	violations := []string{}
+
+	length := utf8.RuneCountInString(plainPassword)
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(plainPassword) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range plainPassword {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if p.BreachedListFile != "" && isBreachedPassword(plainPassword) {
+		violations = append(violations, "appears in a list of breached passwords")
+	}
+
+	return violations
+}
+
+// ValidatePassword enforces the policy for a password about to be set for the
+// user, including the reuse of the current or the last HistorySize passwords.
+// A userID of 0 skips the history check, e.g. for users not created yet.
+func ValidatePassword(tx *gorm.DB, userID uint, plainPassword string) error {
+	policy := GetPasswordPolicy()
+	violations := policy.Check(plainPassword)
+
+	if userID != 0 && policy.HistorySize > 0 {
+		reused, err := isReusedPassword(tx, userID, plainPassword, policy.HistorySize)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, fmt.Sprintf("must not be one of your last %d passwords", policy.HistorySize))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func isReusedPassword(tx *gorm.DB, userID uint, plainPassword string, historySize int) (bool, error) {
+	var hashes []string
+	historyQuery := `
+	SELECT hash FROM passwords WHERE user_id = ?
+	UNION ALL
+	(SELECT hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?);`
+
+	if err := tx.Raw(historyQuery, userID, userID, historySize).Scan(&hashes).Error; err != nil {
+		logger.HighlightedDanger("Unable to read the password history. Message: " + err.Error())
+		return false, fmt.Errorf("unable to read the password history: %w", err)
+	}
+
+	for _, hash := range hashes {
+		if reused, _ := VerifySecret(hash, plainPassword); reused {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordPasswordHistory keeps the hash and prunes the entries beyond the history size.
+func recordPasswordHistory(tx *gorm.DB, userID uint, hash string) error {
+	historySize := GetPasswordPolicy().HistorySize
+	if historySize <= 0 {
+		return nil
+	}
+
+	if err := tx.Exec(`INSERT INTO password_history (hash, user_id) VALUES (?, ?);`, hash, userID).Error; err != nil {
+		return fmt.Errorf("unable to record the password history: %w", err)
+	}
+
+	pruneQuery := `
+	DELETE FROM password_history WHERE user_id = ? AND id NOT IN
+	    (SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?);`
+	if err := tx.Exec(pruneQuery, userID, userID, historySize).Error; err != nil {
+		return fmt.Errorf("unable to prune the password history: %w", err)
+	}
+	return nil
+}
+
+var (
+	breachedPasswordsMu sync.RWMutex
+	breachedPasswords   map[string]struct{}
+)
+
+// LoadBreachedPasswords loads PASSWORD_BREACHED_LIST_FILE once at startup. A
+// configured file which cannot be read is an error, so that the check is never
+// skipped silently.
+func LoadBreachedPasswords() error {
+	file := os.Getenv("PASSWORD_BREACHED_LIST_FILE")
+	if file == "" {
+		return nil
+	}
+
+	list, err := loadBreachedPasswords(file)
+	if err != nil {
+		return fmt.Errorf("unable to load the breached password list %s: %w", file, err)
+	}
+
+	breachedPasswordsMu.Lock()
+	defer breachedPasswordsMu.Unlock()
+	breachedPasswords = list
+	logger.Info("Loaded " + strconv.Itoa(len(list)) + " breached passwords from " + file)
+	return nil
+}
+
+// isBreachedPassword looks the password up in the breached password list, which
+// is compared case insensitively. Passwords are rejected while the list is not
+// loaded.
+func isBreachedPassword(plainPassword string) bool {
+	breachedPasswordsMu.RLock()
+	defer breachedPasswordsMu.RUnlock()
+
+	if breachedPasswords == nil {
+		logger.HighlightedDanger("The breached password list is not loaded, rejecting the password")
+		return true
+	}
+
+	_, found := breachedPasswords[strings.ToLower(plainPassword)]
+	return found
+}
+
+func loadBreachedPasswords(file string) (map[string]struct{}, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			list[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return list, scanner.Err()
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+func envBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewOpaqueToken generates a random url safe token and the hash that should be
+// stored in place of it.
+func NewOpaqueToken() (token string, hash string, err error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", fmt.Errorf("error occurred while generating token: %w", err)
+	}
+
+	token = base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(bytes)
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken returns the hex encoded SHA-256 of the token. The tokens are
+// high entropy, so a fast hash is enough and allows looking them up by hash.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRandomID returns a random 128 bit identifier encoded as hex.
+func NewRandomID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("error occurred while generating id: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
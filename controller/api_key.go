@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type apiKeyController struct {
+	svc services.APIKeyService
+}
+
+type APIKeyController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewAPIKeyController() APIKeyController {
+	return &apiKeyController{
+		svc: services.NewAPIKeyService(),
+	}
+}
+
+func (ctrl *apiKeyController) Create(c *gin.Context) {
+	logger.Info("API Request for creating an api key.")
+	apiKeyDTO := &dtos.APIKeyDTO{}
+	if err := c.ShouldBindBodyWithJSON(apiKeyDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Create api key api stopped due to request body is invalid")
+		return
+	}
+
+	key, apiKey, appErr := ctrl.svc.Create(c.GetUint("userID"), c.GetUint("organizationID"), c.GetUint("branchID"), apiKeyDTO)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Create api key api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "API Key Created. Store the key safely, it will not be shown again.",
+		"result": gin.H{"key": key, "api_key": apiKey}})
+	logger.Info("Create api key api finished")
+}
+
+func (ctrl *apiKeyController) Find(c *gin.Context) {
+	logger.Info("API Request for finding api keys.")
+	apiKeys, appErr := ctrl.svc.FindByUserID(c.GetUint("userID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find api keys api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "API Keys found", "result": gin.H{"api_keys": apiKeys}})
+	logger.Info("Find api keys api finished")
+}
+
+func (ctrl *apiKeyController) DeleteByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for revoking an api key by ID " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid API Key ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Revoke api key by id api stopped")
+		return
+	}
+
+	if appErr := ctrl.svc.RevokeByID(c.GetUint("userID"), uint(id)); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Revoke api key by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "API Key Revoked"})
+	logger.Info("Revoke api key by id api finished")
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type auditEventController struct {
+	svc services.AuditEventService
+}
+
+type AuditEventController interface {
+	Find(c *gin.Context)
+}
+
+func NewAuditEventController() AuditEventController {
+	return &auditEventController{
+		svc: services.NewAuditEventService(),
+	}
+}
+
+func (ctrl *auditEventController) Find(c *gin.Context) {
+	logger.Info("API Request for finding audit events.")
+	filter := models.AuditEventFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid query parameters", "result": gin.H{"error": err.Error()}})
+		logger.Info("Find audit events api stopped due to query parameters are invalid")
+		return
+	}
+
+	filter.Normalize()
+	filter.OrganizationID = c.GetUint("organizationID")
+
+	events, total, appErr := ctrl.svc.Find(filter)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find audit events api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Audit events found", "result": gin.H{"audit_events": events,
+		"page": filter.Page, "page_size": filter.PageSize, "total": total}})
+	logger.Info("Find audit events api finished")
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"treeforms_billing/dtos"
 	"treeforms_billing/logger"
@@ -10,18 +12,32 @@ import (
 )
 
 type authenticatioController struct {
-	authSvc services.AuthenticationService
+	authSvc              services.AuthenticationService
+	passwordResetSvc     services.PasswordResetService
+	emailVerificationSvc services.EmailVerificationService
+	phoneOTPSvc          services.PhoneOTPService
 }
 
 type AuthenticatioController interface {
 	Signup(c *gin.Context)
 	EmailLogin(c *gin.Context)
+	RequestPhoneOTP(c *gin.Context)
+	PhoneLogin(c *gin.Context)
+	VerifyMFALogin(c *gin.Context)
 	RotateRefreshTokenWithNewAccessToken(c *gin.Context)
+	Logout(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	ResendVerificationEmail(c *gin.Context)
 }
 
 func NewAuthenticationController() AuthenticatioController {
 	return &authenticatioController{
-		authSvc: services.NewAuthenticationSevice(),
+		authSvc:              services.NewAuthenticationSevice(),
+		passwordResetSvc:     services.NewPasswordResetService(),
+		emailVerificationSvc: services.NewEmailVerificationService(),
+		phoneOTPSvc:          services.NewPhoneOTPService(),
 	}
 }
 
@@ -41,7 +57,7 @@ func (ctrl *authenticatioController) Signup(c *gin.Context) {
 	}
 
 	logger.Success("API Request for signup success.")
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Signup is successfull."})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Signup is successfull. Please verify your email address to login."})
 }
 
 func (ctrl *authenticatioController) EmailLogin(c *gin.Context) {
@@ -53,7 +69,21 @@ func (ctrl *authenticatioController) EmailLogin(c *gin.Context) {
 		return
 	}
 
-	accessToken, refresh_token, sub, appErr := ctrl.authSvc.EmailLogin(loginDto.Email, loginDto.Password)
+	accessToken, refresh_token, sub, mfaToken, appErr := ctrl.authSvc.EmailLogin(loginDto.Email, loginDto.Password, clientInfo(c, loginDto.DeviceName))
+	if appErr != nil {
+		logger.Danger("API Request for Email Login Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	if mfaToken != "" {
+		logger.Success("API Request for Email Login waiting for MFA verification.")
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "MFA verification required.", "result": gin.H{"mfa_required": true,
+			"mfa_token": mfaToken, "sub": sub}})
+		return
+	}
+
+	result, appErr := withRefreshToken(c, wantsCookieSession(c), gin.H{"access_token": accessToken, "sub": sub}, refresh_token)
 	if appErr != nil {
 		logger.Danger("API Request for Email Login Stopped")
 		appErr.WriteHTTPResponse(c)
@@ -61,25 +91,241 @@ func (ctrl *authenticatioController) EmailLogin(c *gin.Context) {
 	}
 
 	logger.Success("API Request for Email Login success.")
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login is successfull.", "result": gin.H{"access_token": accessToken,
-		"refresh_token": refresh_token, "sub": sub}})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login is successfull.", "result": result})
+}
+
+func (ctrl *authenticatioController) RequestPhoneOTP(c *gin.Context) {
+	logger.Info("API Request for phone OTP")
+	var otpRequestDto dtos.PhoneOTPRequestDTO
+	if err := c.ShouldBindBodyWithJSON(&otpRequestDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if appErr := ctrl.phoneOTPSvc.RequestOTP(otpRequestDto.Phone); appErr != nil {
+		logger.Danger("API Request for phone OTP Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for phone OTP success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "If the phone number is registered, an OTP has been sent."})
+}
+
+func (ctrl *authenticatioController) PhoneLogin(c *gin.Context) {
+	logger.Info("API Request for Phone Login")
+	var phoneLoginDto dtos.PhoneLoginDTO
+	if err := c.ShouldBindBodyWithJSON(&phoneLoginDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	accessToken, refreshToken, sub, mfaToken, appErr := ctrl.authSvc.PhoneLogin(phoneLoginDto.Phone, phoneLoginDto.OTP, clientInfo(c, phoneLoginDto.DeviceName))
+	if appErr != nil {
+		logger.Danger("API Request for Phone Login Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	if mfaToken != "" {
+		logger.Success("API Request for Phone Login waiting for MFA verification.")
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "MFA verification required.", "result": gin.H{"mfa_required": true,
+			"mfa_token": mfaToken, "sub": sub}})
+		return
+	}
+
+	result, appErr := withRefreshToken(c, wantsCookieSession(c), gin.H{"access_token": accessToken, "sub": sub}, refreshToken)
+	if appErr != nil {
+		logger.Danger("API Request for Phone Login Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for Phone Login success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login is successfull.", "result": result})
+}
+
+func (ctrl *authenticatioController) VerifyMFALogin(c *gin.Context) {
+	logger.Info("API Request for MFA Login")
+	var mfaLoginDto dtos.MFALoginDTO
+	if err := c.ShouldBindBodyWithJSON(&mfaLoginDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	accessToken, refreshToken, sub, appErr := ctrl.authSvc.VerifyMFALogin(mfaLoginDto.MFAToken, mfaLoginDto.Code, clientInfo(c, mfaLoginDto.DeviceName))
+	if appErr != nil {
+		logger.Danger("API Request for MFA Login Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	result, appErr := withRefreshToken(c, wantsCookieSession(c), gin.H{"access_token": accessToken, "sub": sub}, refreshToken)
+	if appErr != nil {
+		logger.Danger("API Request for MFA Login Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for MFA Login success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login is successfull.", "result": result})
 }
 
 func (ctrl *authenticatioController) RotateRefreshTokenWithNewAccessToken(c *gin.Context) {
 	var refreshTokenDto dtos.RotateRefreshTokenDTO
-	if err := c.ShouldBindBodyWithJSON(&refreshTokenDto); err != nil {
+	// Cookie mode clients may send no body at all.
+	if err := c.ShouldBindBodyWithJSON(&refreshTokenDto); err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
 		return
 	}
 
-	accessToken, refreshToken, appErr := ctrl.authSvc.RotateRefreshTokenWithNewAccessToken(refreshTokenDto.RefreshToken, refreshTokenDto.UserID)
+	presentedToken, cookieMode, appErr := refreshTokenFromRequest(c, refreshTokenDto.RefreshToken)
 	if appErr != nil {
 		appErr.WriteHTTPResponse(c)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "New accesstoken and refresh token available", "result": gin.H{
-		"refresh_token": refreshToken,
-		"access_token":  accessToken,
-	}})
+	accessToken, refreshToken, appErr := ctrl.authSvc.RotateRefreshTokenWithNewAccessToken(presentedToken, refreshTokenDto.UserID, clientInfo(c, ""))
+	if appErr != nil {
+		if cookieMode && appErr.GetHTTPStatusCode() == http.StatusUnauthorized {
+			clearRefreshTokenCookies(c)
+		}
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	result, appErr := withRefreshToken(c, cookieMode, gin.H{"access_token": accessToken}, refreshToken)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "New accesstoken and refresh token available", "result": result})
+}
+
+func (ctrl *authenticatioController) Logout(c *gin.Context) {
+	logger.Info("API Request for logout")
+	var logoutDto dtos.LogoutDTO
+	if err := c.ShouldBindBodyWithJSON(&logoutDto); err != nil && !errors.Is(err, io.EOF) {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	refreshToken, cookieMode, appErr := refreshTokenFromRequest(c, logoutDto.RefreshToken)
+	if appErr != nil {
+		logger.Danger("API Request for logout Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	if appErr := ctrl.authSvc.Logout(refreshToken); appErr != nil {
+		logger.Danger("API Request for logout Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	if cookieMode {
+		clearRefreshTokenCookies(c)
+	}
+
+	logger.Success("API Request for logout success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Logged out."})
+}
+
+func (ctrl *authenticatioController) ForgotPassword(c *gin.Context) {
+	logger.Info("API Request for forgot password")
+	var forgotPasswordDto dtos.ForgotPasswordDTO
+	if err := c.ShouldBindBodyWithJSON(&forgotPasswordDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if appErr := ctrl.passwordResetSvc.RequestPasswordReset(forgotPasswordDto.Email); appErr != nil {
+		logger.Danger("API Request for forgot password Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for forgot password success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "If the email is registered, a password reset link has been sent."})
+}
+
+func (ctrl *authenticatioController) ResetPassword(c *gin.Context) {
+	logger.Info("API Request for reset password")
+	var resetPasswordDto dtos.ResetPasswordDTO
+	if err := c.ShouldBindBodyWithJSON(&resetPasswordDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if appErr := ctrl.passwordResetSvc.ResetPassword(resetPasswordDto.Token, resetPasswordDto.Password, resetPasswordDto.ConfirmPassword); appErr != nil {
+		logger.Danger("API Request for reset password Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for reset password success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password has been reset. Please login again."})
+}
+
+func (ctrl *authenticatioController) VerifyEmail(c *gin.Context) {
+	logger.Info("API Request for verify email")
+	var verifyEmailDto dtos.VerifyEmailDTO
+	if err := c.ShouldBindBodyWithJSON(&verifyEmailDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if appErr := ctrl.emailVerificationSvc.VerifyEmail(verifyEmailDto.Token); appErr != nil {
+		logger.Danger("API Request for verify email Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for verify email success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Email verified. You can login now."})
+}
+
+func (ctrl *authenticatioController) ResendVerificationEmail(c *gin.Context) {
+	logger.Info("API Request for resend verification email")
+	var resendDto dtos.ResendVerificationEmailDTO
+	if err := c.ShouldBindBodyWithJSON(&resendDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if appErr := ctrl.emailVerificationSvc.ResendVerification(resendDto.Email); appErr != nil {
+		logger.Danger("API Request for resend verification email Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for resend verification email success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "If the email is registered and not verified yet, a verification link has been sent."})
+}
+
+func clientInfo(c *gin.Context, deviceName string) dtos.ClientInfo {
+	return dtos.ClientInfo{
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		RequestID:  c.GetString("requestID"),
+	}
+}
+
+func actor(c *gin.Context) dtos.Actor {
+	return dtos.Actor{
+		UserID:         c.GetUint("userID"),
+		ImpersonatorID: c.GetUint("impersonatorID"),
+		OrganizationID: c.GetUint("organizationID"),
+		Client:         clientInfo(c, ""),
+	}
 }
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type branchController struct {
+	svc services.BranchService
+}
+
+type BranchController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	UpdateByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewBranchController() BranchController {
+	return &branchController{
+		svc: services.NewBranchService(),
+	}
+}
+
+func (ctrl *branchController) Create(c *gin.Context) {
+	logger.Info("API Request for creating a branch.")
+	branchDTO := &dtos.BranchDTO{}
+	if err := c.ShouldBindBodyWithJSON(branchDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Create branch api stopped due to request body is invalid")
+		return
+	}
+
+	branch, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Create(branchDTO)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Create branch api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branch Created", "result": gin.H{"branch": branch}})
+	logger.Info("Create branch api finished")
+}
+
+func (ctrl *branchController) Find(c *gin.Context) {
+	logger.Info("API Request for finding branches.")
+	branches, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Find()
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find branches api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branches found", "result": gin.H{"branches": branches}})
+	logger.Info("Find branches api finished")
+}
+
+func (ctrl *branchController) FindByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for finding branch by id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Branch ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Find branch by id api stopped")
+		return
+	}
+
+	branch, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).FindByID(uint(id))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find branch by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branch Found", "result": gin.H{"branch": branch}})
+	logger.Info("Find branch by id api finished")
+}
+
+func (ctrl *branchController) UpdateByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for updating a branch by ID " + idStr + ".")
+
+	branchDTO := &dtos.BranchDTO{}
+	if err := c.ShouldBindBodyWithJSON(branchDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update branch by id api stopped due to request body is invalid")
+		return
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Branch ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update branch by id api stopped")
+		return
+	}
+
+	branch, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).UpdateByID(uint(id), branchDTO)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Update branch by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branch Updated", "result": gin.H{"branch": branch}})
+	logger.Info("Update branch by id api finished")
+}
+
+func (ctrl *branchController) DeleteByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for deleting a branch by ID " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Branch ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Delete branch by id api stopped")
+		return
+	}
+
+	if appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).DeleteByID(uint(id)); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Delete branch by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branch Deleted"})
+	logger.Info("Delete branch by id api finished")
+}
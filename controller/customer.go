@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type customerController struct {
+	svc services.CustomerService
+}
+
+type CustomerController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	UpdateByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewCustomerController() CustomerController {
+	return &customerController{
+		svc: services.NewCustomerService(),
+	}
+}
+
+// scoped limits the service to the organization and the branch of the request.
+func (ctrl *customerController) scoped(c *gin.Context) services.CustomerService {
+	return ctrl.svc.ForOrganization(c.GetUint("organizationID")).ForBranch(c.GetUint("branchID"))
+}
+
+func (ctrl *customerController) Create(c *gin.Context) {
+	logger.Info("API Request for creating a customer.")
+	customerDTO := &dtos.CustomerDTO{}
+	if err := c.ShouldBindBodyWithJSON(customerDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Create customer api stopped due to request body is invalid")
+		return
+	}
+
+	customer, appErr := ctrl.scoped(c).Create(customerDTO, actor(c))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Create customer api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Customer Created", "result": gin.H{"customer": customer}})
+	logger.Info("Create customer api finished")
+}
+
+func (ctrl *customerController) Find(c *gin.Context) {
+	logger.Info("API Request for finding customers.")
+	filter := models.CustomerFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid query parameters", "result": gin.H{"error": err.Error()}})
+		logger.Info("Find customers api stopped due to query parameters are invalid")
+		return
+	}
+
+	filter.Normalize()
+
+	customers, total, appErr := ctrl.scoped(c).Find(filter)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find customers api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Customers found", "result": gin.H{"customers": customers,
+		"page": filter.Page, "page_size": filter.PageSize, "total": total}})
+	logger.Info("Find customers api finished")
+}
+
+func (ctrl *customerController) FindByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for finding customer by id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Customer ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Find customer by id api stopped")
+		return
+	}
+
+	customer, appErr := ctrl.scoped(c).FindByID(uint(id))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find customer by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Customer Found", "result": gin.H{"customer": customer}})
+	logger.Info("Find customer by id api finished")
+}
+
+func (ctrl *customerController) UpdateByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for updating a customer by ID " + idStr + ".")
+
+	customerDTO := &dtos.CustomerDTO{}
+	if err := c.ShouldBindBodyWithJSON(customerDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update customer by id api stopped due to request body is invalid")
+		return
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Customer ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update customer by id api stopped")
+		return
+	}
+
+	customer, appErr := ctrl.scoped(c).UpdateByID(uint(id), customerDTO, actor(c))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Update customer by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Customer Updated", "result": gin.H{"customer": customer}})
+	logger.Info("Update customer by id api finished")
+}
+
+func (ctrl *customerController) DeleteByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for deleting a customer by ID " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Customer ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Delete customer by id api stopped")
+		return
+	}
+
+	if appErr := ctrl.scoped(c).DeleteByID(uint(id), actor(c)); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Delete customer by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Customer Deleted"})
+	logger.Info("Delete customer by id api finished")
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/application_types"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type impersonationController struct {
+	svc services.ImpersonationService
+}
+
+type ImpersonationController interface {
+	Start(c *gin.Context)
+	Stop(c *gin.Context)
+}
+
+func NewImpersonationController() ImpersonationController {
+	return &impersonationController{
+		svc: services.NewImpersonationService(),
+	}
+}
+
+func (ctrl *impersonationController) Start(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for impersonating the user " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid User ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Impersonate user api stopped")
+		return
+	}
+
+	var impersonateDto dtos.ImpersonateDTO
+	if err := c.ShouldBindBodyWithJSON(&impersonateDto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Impersonate user api stopped due to request body is invalid")
+		return
+	}
+
+	accessToken, claims, appErr := ctrl.svc.Impersonate(c.GetUint("userID"), c.GetUint("organizationID"), uint(id), impersonateDto.Reason, clientInfo(c, ""))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Impersonate user api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Impersonation started", "result": gin.H{"access_token": accessToken,
+		"sub": claims.UserID, "expires_at": claims.ExpiresAt.Time}})
+	logger.Info("Impersonate user api finished")
+}
+
+func (ctrl *impersonationController) Stop(c *gin.Context) {
+	logger.Info("API Request for stopping the impersonation.")
+	claims, _ := c.MustGet("accessToken").(*application_types.AccessToken)
+
+	if appErr := ctrl.svc.Stop(claims, clientInfo(c, "")); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Stop impersonation api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Impersonation stopped"})
+	logger.Info("Stop impersonation api finished")
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type invitationController struct {
+	svc services.InvitationService
+}
+
+type InvitationController interface {
+	Find(c *gin.Context)
+	Resend(c *gin.Context)
+	Revoke(c *gin.Context)
+	Accept(c *gin.Context)
+}
+
+func NewInvitationController() InvitationController {
+	return &invitationController{
+		svc: services.NewInvitationService(),
+	}
+}
+
+func (ctrl *invitationController) Find(c *gin.Context) {
+	logger.Info("API Request for finding pending invitations.")
+	invitations, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).FindPending()
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find invitations api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Invitations found", "result": gin.H{"invitations": invitations}})
+	logger.Info("Find invitations api finished")
+}
+
+func (ctrl *invitationController) Resend(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for resending the invitation " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Invitation ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Resend invitation api stopped")
+		return
+	}
+
+	invitation, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Resend(uint(id), c.GetString("userRole"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Resend invitation api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Invitation resent", "result": gin.H{"invitation": invitation}})
+	logger.Info("Resend invitation api finished")
+}
+
+func (ctrl *invitationController) Revoke(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for revoking the invitation " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Invitation ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Revoke invitation api stopped")
+		return
+	}
+
+	if appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Revoke(uint(id), c.GetString("userRole")); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Revoke invitation api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Invitation revoked"})
+	logger.Info("Revoke invitation api finished")
+}
+
+func (ctrl *invitationController) Accept(c *gin.Context) {
+	logger.Info("API Request for accepting an invitation.")
+	var acceptDto dtos.AcceptInvitationDTO
+	if err := c.ShouldBindBodyWithJSON(&acceptDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if appErr := ctrl.svc.Accept(acceptDto); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Accept invitation api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Your account is activated. You can now login with your password."})
+	logger.Info("Accept invitation api finished")
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"net/http"
+	"treeforms_billing/keyring"
+
+	"github.com/gin-gonic/gin"
+)
+
+type jwksController struct{}
+
+type JWKSController interface {
+	GetJWKS(c *gin.Context)
+}
+
+func NewJWKSController() JWKSController {
+	return &jwksController{}
+}
+
+// GetJWKS serves the public signing keys in the standard JWKS format instead of
+// the usual response envelope, since it is read by JWT libraries.
+func (ctrl *jwksController) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keyring.Get().JWKS())
+}
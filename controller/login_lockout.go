@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type loginLockoutController struct {
+	svc services.LoginThrottleService
+}
+
+type LoginLockoutController interface {
+	Find(c *gin.Context)
+	Clear(c *gin.Context)
+}
+
+func NewLoginLockoutController() LoginLockoutController {
+	return &loginLockoutController{
+		svc: services.NewLoginThrottleService(),
+	}
+}
+
+func (ctrl *loginLockoutController) Find(c *gin.Context) {
+	logger.Info("API Request for finding login lockouts.")
+	lockouts, appErr := ctrl.svc.FindLockouts()
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find login lockouts api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login lockouts found", "result": gin.H{"lockouts": lockouts}})
+	logger.Info("Find login lockouts api finished")
+}
+
+func (ctrl *loginLockoutController) Clear(c *gin.Context) {
+	logger.Info("API Request for clearing a login lockout.")
+	clearDTO := &dtos.ClearLoginLockoutDTO{}
+	if err := c.ShouldBindBodyWithJSON(clearDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Clear login lockout api stopped due to request body is invalid")
+		return
+	}
+
+	if appErr := ctrl.svc.ClearLockout(clearDTO.Subject, clearDTO.Value); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Clear login lockout api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login lockout cleared"})
+	logger.Info("Clear login lockout api finished")
+}
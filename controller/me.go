@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"treeforms_billing/application_types"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type meController struct {
+	userSvc   services.UserService
+	authSvc   services.AuthenticationService
+	passSvc   services.PasswordService
+	memberSvc services.OrganizationMemberService
+}
+
+type MeController interface {
+	Find(c *gin.Context)
+	Update(c *gin.Context)
+	ChangePassword(c *gin.Context)
+	FindOrganizations(c *gin.Context)
+	SwitchOrganization(c *gin.Context)
+	FindBranches(c *gin.Context)
+	SwitchBranch(c *gin.Context)
+}
+
+func NewMeController() MeController {
+	return &meController{
+		userSvc:   services.NewUserService(),
+		authSvc:   services.NewAuthenticationSevice(),
+		passSvc:   services.NewPasswordService(),
+		memberSvc: services.NewOrganizationMemberService(),
+	}
+}
+
+// Find returns the current user with their role in the organization the
+// request acts in.
+func (ctrl *meController) Find(c *gin.Context) {
+	logger.Info("API Request for finding the current user.")
+	user, appErr := ctrl.userSvc.ForOrganization(c.GetUint("organizationID")).FindByID(c.GetUint("userID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find current user api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User found", "result": gin.H{"user": user}})
+	logger.Info("Find current user api finished")
+}
+
+// Update changes the profile of the current user. Only the name and phone can
+// be changed here; email, role and status are managed elsewhere. The phone logs
+// in with an OTP, so a new phone needs the current password.
+func (ctrl *meController) Update(c *gin.Context) {
+	logger.Info("API Request for updating the current user.")
+	var profileDto dtos.UpdateProfileDTO
+	if err := c.ShouldBindBodyWithJSON(&profileDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	if strings.TrimSpace(profileDto.Phone) != "" {
+		current, appErr := ctrl.userSvc.FindByID(c.GetUint("userID"))
+		if appErr != nil {
+			appErr.WriteHTTPResponse(c)
+			logger.Info("Update current user api stopped")
+			return
+		}
+		if profileDto.Phone != current.Phone {
+			if appErr := ctrl.passSvc.VerifyCurrentPassword(current.ID, profileDto.CurrentPassword, "Unable to change phone"); appErr != nil {
+				appErr.WriteHTTPResponse(c)
+				logger.Info("Update current user api stopped")
+				return
+			}
+		}
+	}
+
+	user, appErr := ctrl.userSvc.UpdateByID(c.GetUint("userID"), &dtos.UserDTO{Name: profileDto.Name, Phone: profileDto.Phone}, actor(c))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Update current user api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Profile updated", "result": gin.H{"user": user}})
+	logger.Info("Update current user api finished")
+}
+
+func (ctrl *meController) ChangePassword(c *gin.Context) {
+	logger.Info("API Request for changing the password of the current user.")
+	var changePasswordDto dtos.ChangePasswordDTO
+	if err := c.ShouldBindBodyWithJSON(&changePasswordDto); err != nil {
+		logger.Danger("Invalid Payload. Message: " + err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Unable to read request body!", "result": gin.H{"error": err.Error()}})
+		return
+	}
+
+	accessToken, refreshToken, appErr := ctrl.authSvc.ChangePassword(c.GetUint("userID"), changePasswordDto, clientInfo(c, changePasswordDto.DeviceName))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Change password api stopped")
+		return
+	}
+
+	result, appErr := withRefreshToken(c, wantsCookieSession(c), gin.H{"access_token": accessToken, "sub": c.GetUint("userID")}, refreshToken)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Change password api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password changed. Other sessions are logged out.", "result": result})
+	logger.Info("Change password api finished")
+}
+
+// FindOrganizations lists the organizations of the current user with their role
+// in each. The organization the request acts in is marked active.
+func (ctrl *meController) FindOrganizations(c *gin.Context) {
+	logger.Info("API Request for finding the organizations of the current user.")
+	members, appErr := ctrl.memberSvc.FindByUserID(c.GetUint("userID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find current user organizations api stopped")
+		return
+	}
+
+	organizations := make([]gin.H, 0, len(members))
+	for _, member := range members {
+		organizations = append(organizations, gin.H{"organization": member.Organization, "role": member.Role, "owner": member.Owner,
+			"active": member.OrganizationID == c.GetUint("organizationID")})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organizations found", "result": gin.H{"organizations": organizations}})
+	logger.Info("Find current user organizations api finished")
+}
+
+// SwitchOrganization re-issues the access token for another organization of
+// the current user. The refresh token of the session stays the same.
+func (ctrl *meController) SwitchOrganization(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for switching the current user to the organization " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Organization ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Switch organization api stopped")
+		return
+	}
+
+	claims, _ := c.MustGet("accessToken").(*application_types.AccessToken)
+	accessToken, appErr := ctrl.authSvc.SwitchOrganization(claims, uint(id), clientInfo(c, ""))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Switch organization api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organization switched", "result": gin.H{"access_token": accessToken,
+		"organization_id": uint(id)}})
+	logger.Info("Switch organization api finished")
+}
+
+// FindBranches lists the branches of the current organization the current user
+// may work in. The branch the request acts in is marked active.
+func (ctrl *meController) FindBranches(c *gin.Context) {
+	logger.Info("API Request for finding the branches of the current user.")
+	member, appErr := ctrl.memberSvc.FindMember(c.GetUint("organizationID"), c.GetUint("userID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find current user branches api stopped")
+		return
+	}
+
+	branches, appErr := ctrl.memberSvc.FindBranches(member)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find current user branches api stopped")
+		return
+	}
+
+	result := make([]gin.H, 0, len(branches))
+	for _, branch := range branches {
+		result = append(result, gin.H{"branch": branch, "active": branch.ID == c.GetUint("branchID")})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branches found", "result": gin.H{"branches": result,
+		"all_branches": c.GetUint("branchID") == 0}})
+	logger.Info("Find current user branches api finished")
+}
+
+// SwitchBranch re-issues the access token for another branch of the current
+// organization. The id 0 switches to every branch, for members who are not
+// restricted to some branches.
+func (ctrl *meController) SwitchBranch(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for switching the current user to the branch " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Branch ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Switch branch api stopped")
+		return
+	}
+
+	claims, _ := c.MustGet("accessToken").(*application_types.AccessToken)
+	accessToken, appErr := ctrl.authSvc.SwitchBranch(claims, uint(id), clientInfo(c, ""))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Switch branch api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Branch switched", "result": gin.H{"access_token": accessToken,
+		"branch_id": uint(id)}})
+	logger.Info("Switch branch api finished")
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mfaController struct {
+	svc services.MFAService
+}
+
+type MFAController interface {
+	Enroll(c *gin.Context)
+	Confirm(c *gin.Context)
+	ResetByUserID(c *gin.Context)
+}
+
+func NewMFAController() MFAController {
+	return &mfaController{
+		svc: services.NewMFAService(),
+	}
+}
+
+func (ctrl *mfaController) Enroll(c *gin.Context) {
+	logger.Info("API Request for MFA enrollment.")
+	secret, uri, appErr := ctrl.svc.Enroll(c.GetUint("userID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("MFA enrollment api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Scan the QR code or enter the secret in your authenticator app, then confirm with a code.",
+		"result": gin.H{"secret": secret, "otpauth_uri": uri}})
+	logger.Info("MFA enrollment api finished")
+}
+
+func (ctrl *mfaController) Confirm(c *gin.Context) {
+	logger.Info("API Request for MFA confirmation.")
+	mfaCodeDTO := &dtos.MFACodeDTO{}
+	if err := c.ShouldBindBodyWithJSON(mfaCodeDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("MFA confirmation api stopped due to request body is invalid")
+		return
+	}
+
+	recoveryCodes, appErr := ctrl.svc.Confirm(c.GetUint("userID"), mfaCodeDTO.Code)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("MFA confirmation api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "MFA enabled. Store the recovery codes safely, they will not be shown again.",
+		"result": gin.H{"recovery_codes": recoveryCodes}})
+	logger.Info("MFA confirmation api finished")
+}
+
+func (ctrl *mfaController) ResetByUserID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for resetting MFA of the user id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid User ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Reset MFA api stopped")
+		return
+	}
+
+	if appErr := ctrl.svc.Reset(uint(id)); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Reset MFA api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "MFA Reset"})
+	logger.Info("Reset MFA api finished")
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizationController struct {
+	svc services.OrganizationService
+}
+
+type OrganizationController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	UpdateByID(c *gin.Context)
+}
+
+func NewOrganizationController() OrganizationController {
+	return &organizationController{
+		svc: services.NewOrganizationService(),
+	}
+}
+
+func (ctrl *organizationController) Create(c *gin.Context) {
+	logger.Info("API Request for creating an organization.")
+	organizationDTO := &dtos.OrganizationDTO{}
+	if err := c.ShouldBindBodyWithJSON(organizationDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Create organization api stopped due to request body is invalid")
+		return
+	}
+
+	organization, appErr := ctrl.svc.Create(organizationDTO)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Create organization api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organization Created", "result": gin.H{"organization": organization}})
+	logger.Info("Create organization api finished")
+}
+
+func (ctrl *organizationController) Find(c *gin.Context) {
+	logger.Info("API Request for finding organizations.")
+	organizations, appErr := ctrl.svc.Find()
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find organizations api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organizations found", "result": gin.H{"organizations": organizations}})
+	logger.Info("Find organizations api finished")
+}
+
+func (ctrl *organizationController) FindByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for finding organization by id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Organization ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Find organization by id api stopped")
+		return
+	}
+
+	organization, appErr := ctrl.svc.FindByID(uint(id))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find organization by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organization Found", "result": gin.H{"organization": organization}})
+	logger.Info("Find organization by id api finished")
+}
+
+func (ctrl *organizationController) UpdateByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for updating an organization by ID " + idStr + ".")
+
+	organizationDTO := &dtos.OrganizationDTO{}
+	if err := c.ShouldBindBodyWithJSON(organizationDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update organization by id api stopped due to request body is invalid")
+		return
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Organization ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update organization by id api stopped")
+		return
+	}
+
+	organization, appErr := ctrl.svc.UpdateByID(uint(id), organizationDTO)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Update organization by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organization Updated", "result": gin.H{"organization": organization}})
+	logger.Info("Update organization by id api finished")
+}
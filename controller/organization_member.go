@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizationMemberController struct {
+	svc services.OrganizationMemberService
+}
+
+type OrganizationMemberController interface {
+	Add(c *gin.Context)
+	Find(c *gin.Context)
+	UpdateByUserID(c *gin.Context)
+	RemoveByUserID(c *gin.Context)
+}
+
+func NewOrganizationMemberController() OrganizationMemberController {
+	return &organizationMemberController{
+		svc: services.NewOrganizationMemberService(),
+	}
+}
+
+// Add invites a user into the current organization. The response is the same
+// whether the email has an account or not.
+func (ctrl *organizationMemberController) Add(c *gin.Context) {
+	logger.Info("API Request for adding an organization member.")
+	memberDTO := &dtos.OrganizationMemberDTO{}
+	if err := c.ShouldBindBodyWithJSON(memberDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Add organization member api stopped due to request body is invalid")
+		return
+	}
+
+	invitation, appErr := ctrl.svc.Add(c.GetUint("organizationID"), memberDTO, actor(c))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Add organization member api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "An invitation has been sent.", "result": gin.H{"invitation": invitation}})
+	logger.Info("Add organization member api finished")
+}
+
+func (ctrl *organizationMemberController) Find(c *gin.Context) {
+	logger.Info("API Request for finding organization members.")
+	members, appErr := ctrl.svc.Find(c.GetUint("organizationID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find organization members api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Members found", "result": gin.H{"members": members}})
+	logger.Info("Find organization members api finished")
+}
+
+func (ctrl *organizationMemberController) UpdateByUserID(c *gin.Context) {
+	idStr := c.Param("userID")
+	logger.Info("API Request for updating the organization member " + idStr + ".")
+
+	memberDTO := &dtos.OrganizationMemberDTO{}
+	if err := c.ShouldBindBodyWithJSON(memberDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update organization member api stopped due to request body is invalid")
+		return
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid User ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update organization member api stopped")
+		return
+	}
+
+	member, appErr := ctrl.svc.UpdateByUserID(c.GetUint("organizationID"), uint(id), memberDTO, actor(c))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Update organization member api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Member Updated", "result": gin.H{"member": member}})
+	logger.Info("Update organization member api finished")
+}
+
+// RemoveByUserID removes the member from the current organization. Their
+// account and other memberships are kept.
+func (ctrl *organizationMemberController) RemoveByUserID(c *gin.Context) {
+	idStr := c.Param("userID")
+	logger.Info("API Request for removing the organization member " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid User ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Remove organization member api stopped")
+		return
+	}
+
+	if appErr := ctrl.svc.RemoveByUserID(c.GetUint("organizationID"), uint(id), actor(c)); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Remove organization member api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Member Removed"})
+	logger.Info("Remove organization member api finished")
+}
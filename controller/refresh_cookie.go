@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Browser clients opt into cookie mode by sending "X-Session-Mode: cookie" on
+// login. The refresh token is then kept in an HttpOnly cookie that only the
+// refresh endpoints receive, and a readable CSRF cookie is set alongside it.
+// Requests authenticated by the refresh cookie must echo the CSRF cookie in the
+// X-CSRF-Token header (double-submit).
+const (
+	sessionModeHeader      = "X-Session-Mode"
+	sessionModeCookie      = "cookie"
+	csrfTokenHeader        = "X-CSRF-Token"
+	refreshTokenCookiePath = "/api/v1/authentication/refresh-token"
+)
+
+type refreshCookieConfig struct {
+	Name     string
+	CSRFName string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+func getRefreshCookieConfig() refreshCookieConfig {
+	cfg := refreshCookieConfig{
+		Name:     os.Getenv("REFRESH_COOKIE_NAME"),
+		CSRFName: os.Getenv("CSRF_COOKIE_NAME"),
+		Domain:   os.Getenv("REFRESH_COOKIE_DOMAIN"),
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if cfg.Name == "" {
+		cfg.Name = "tfb_refresh_token"
+	}
+	if cfg.CSRFName == "" {
+		cfg.CSRFName = "tfb_csrf_token"
+	}
+	if secure, err := strconv.ParseBool(os.Getenv("REFRESH_COOKIE_SECURE")); err == nil {
+		cfg.Secure = secure
+	}
+	switch strings.ToLower(os.Getenv("REFRESH_COOKIE_SAMESITE")) {
+	case "lax":
+		cfg.SameSite = http.SameSiteLaxMode
+	case "none":
+		// Browsers reject SameSite=None cookies that are not Secure.
+		cfg.SameSite = http.SameSiteNoneMode
+		cfg.Secure = true
+	}
+	return cfg
+}
+
+func wantsCookieSession(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader(sessionModeHeader), sessionModeCookie)
+}
+
+// withRefreshToken adds the refresh token to the response result. In cookie mode
+// the token is set as an HttpOnly cookie and only the CSRF token is returned.
+func withRefreshToken(c *gin.Context, cookieMode bool, result gin.H, refreshToken string) (gin.H, *application_types.ApplicationError) {
+	if !cookieMode {
+		result["refresh_token"] = refreshToken
+		return result, nil
+	}
+
+	csrfToken, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate the csrf token. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to start the session", err)
+	}
+
+	cfg := getRefreshCookieConfig()
+	maxAge := int(services.RefreshTokenTTL.Seconds())
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.Name,
+		Value:    refreshToken,
+		Path:     refreshTokenCookiePath,
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: cfg.SameSite,
+	})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.CSRFName,
+		Value:    csrfToken,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
+	})
+
+	result["csrf_token"] = csrfToken
+	return result, nil
+}
+
+// refreshTokenFromRequest returns the refresh token from the body, or from the
+// refresh cookie after checking the CSRF header. cookieMode reports whether the
+// cookie was used.
+func refreshTokenFromRequest(c *gin.Context, bodyToken string) (token string, cookieMode bool, appErr *application_types.ApplicationError) {
+	if bodyToken != "" {
+		return bodyToken, false, nil
+	}
+
+	cfg := getRefreshCookieConfig()
+	token, err := c.Cookie(cfg.Name)
+	if err != nil || token == "" {
+		logger.Warning("No refresh token in the body or the cookie")
+		return "", false, application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid refresh token", fmt.Errorf("Refresh token is required"))
+	}
+
+	csrfCookie, err := c.Cookie(cfg.CSRFName)
+	csrfHeader := c.GetHeader(csrfTokenHeader)
+	if err != nil || csrfCookie == "" || subtle.ConstantTimeCompare([]byte(csrfCookie), []byte(csrfHeader)) != 1 {
+		logger.Warning("CSRF token mismatch for the refresh cookie")
+		return "", true, application_types.NewApplicationError(false, http.StatusForbidden, "Invalid CSRF token", fmt.Errorf("The %s header does not match the csrf cookie", csrfTokenHeader))
+	}
+
+	return token, true, nil
+}
+
+func clearRefreshTokenCookies(c *gin.Context) {
+	cfg := getRefreshCookieConfig()
+	for _, cookie := range []*http.Cookie{
+		{Name: cfg.Name, Path: refreshTokenCookiePath, HttpOnly: true},
+		{Name: cfg.CSRFName, Path: "/"},
+	} {
+		cookie.Domain = cfg.Domain
+		cookie.MaxAge = -1
+		cookie.Secure = cfg.Secure
+		cookie.SameSite = cfg.SameSite
+		http.SetCookie(c.Writer, cookie)
+	}
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type roleController struct {
+	svc services.RoleService
+}
+
+type RoleController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	UpdateByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+	FindPermissions(c *gin.Context)
+}
+
+func NewRoleController() RoleController {
+	return &roleController{
+		svc: services.NewRoleService(),
+	}
+}
+
+func (ctrl *roleController) Create(c *gin.Context) {
+	logger.Info("API Request for creating a role.")
+	roleDTO := &dtos.RoleDTO{}
+	if err := c.ShouldBindBodyWithJSON(roleDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Create role api stopped due to request body is invalid")
+		return
+	}
+
+	role, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Create(roleDTO, c.GetString("userRole"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Create role api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Role Created", "result": gin.H{"role": role}})
+	logger.Info("Create role api finished")
+}
+
+func (ctrl *roleController) Find(c *gin.Context) {
+	logger.Info("API Request for finding roles.")
+	roles, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Find()
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find roles api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Roles found", "result": gin.H{"roles": roles}})
+	logger.Info("Find roles api finished")
+}
+
+func (ctrl *roleController) FindByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for finding role by id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Role ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Find role by id api stopped")
+		return
+	}
+
+	role, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).FindByID(uint(id))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find role by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Role found", "result": gin.H{"role": role}})
+	logger.Info("Find role by id api finished")
+}
+
+func (ctrl *roleController) UpdateByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for updating role by id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Role ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update role by id api stopped")
+		return
+	}
+
+	roleDTO := &dtos.RoleDTO{}
+	if err := c.ShouldBindBodyWithJSON(roleDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+		logger.Info("Update role by id api stopped due to request body is invalid")
+		return
+	}
+
+	role, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).UpdateByID(uint(id), roleDTO, c.GetString("userRole"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Update role by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Role Updated", "result": gin.H{"role": role}})
+	logger.Info("Update role by id api finished")
+}
+
+func (ctrl *roleController) DeleteByID(c *gin.Context) {
+	idStr := c.Param("id")
+	logger.Info("API Request for deleting role by id " + idStr + ".")
+
+	id, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Role ID", "result": gin.H{"error": err.Error()}})
+		logger.Info("Delete role by id api stopped")
+		return
+	}
+
+	if appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).DeleteByID(uint(id), c.GetString("userRole")); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Delete role by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Role Deleted"})
+	logger.Info("Delete role by id api finished")
+}
+
+func (ctrl *roleController) FindPermissions(c *gin.Context) {
+	logger.Info("API Request for finding permissions.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Permissions found", "result": gin.H{"permissions": models.Permissions}})
+}
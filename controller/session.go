@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"net/http"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionController struct {
+	svc services.SessionService
+}
+
+type SessionController interface {
+	Find(c *gin.Context)
+	DeleteByID(c *gin.Context)
+	DeleteAll(c *gin.Context)
+}
+
+func NewSessionController() SessionController {
+	return &sessionController{
+		svc: services.NewSessionService(),
+	}
+}
+
+func (ctrl *sessionController) Find(c *gin.Context) {
+	logger.Info("API Request for finding sessions.")
+	sessions, appErr := ctrl.svc.FindByUserID(c.GetUint("userID"))
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Find sessions api stopped")
+		return
+	}
+
+	currentSessionID := c.GetString("sessionID")
+	for _, session := range sessions {
+		session.Current = session.FamilyID == currentSessionID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Sessions found", "result": gin.H{"sessions": sessions}})
+	logger.Info("Find sessions api finished")
+}
+
+func (ctrl *sessionController) DeleteByID(c *gin.Context) {
+	id := c.Param("id")
+	logger.Info("API Request for deleting a session by ID " + id + ".")
+
+	if appErr := ctrl.svc.RevokeByID(c.GetUint("userID"), id); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Delete session by id api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Session Deleted"})
+	logger.Info("Delete session by id api finished")
+}
+
+func (ctrl *sessionController) DeleteAll(c *gin.Context) {
+	logger.Info("API Request for deleting all sessions.")
+	if appErr := ctrl.svc.RevokeAll(c.GetUint("userID")); appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		logger.Info("Delete all sessions api stopped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Logged out from all devices"})
+	logger.Info("Delete all sessions api finished")
+}
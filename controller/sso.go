@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/logger"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The login binds the state to the browser with an HttpOnly cookie holding its
+// hash, so that a callback URL made for another browser is rejected (login
+// CSRF). The browser cannot send X-Session-Mode on the redirect back from the
+// provider, so cookie mode is requested at the login with the header or the
+// session_mode query parameter and remembered in a cookie as well.
+const (
+	oidcStateCookie       = "tfb_oidc_state"
+	oidcSessionModeCookie = "tfb_oidc_session_mode"
+	oidcCookiePath        = "/api/v1/authentication/oidc"
+)
+
+type ssoController struct {
+	authSvc services.AuthenticationService
+	oidcSvc services.OIDCService
+}
+
+type SSOController interface {
+	Login(c *gin.Context)
+	Callback(c *gin.Context)
+}
+
+func NewSSOController() SSOController {
+	return &ssoController{
+		authSvc: services.NewAuthenticationSevice(),
+		oidcSvc: services.NewOIDCService(),
+	}
+}
+
+// Login redirects the browser to the authorization endpoint of the provider.
+func (ctrl *ssoController) Login(c *gin.Context) {
+	logger.Info("API Request for SSO Login")
+	url, state, appErr := ctrl.oidcSvc.AuthorizationURL(c.Param("provider"))
+	if appErr != nil {
+		logger.Danger("API Request for SSO Login Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	sessionMode := ""
+	if wantsCookieSession(c) || c.Query("session_mode") == sessionModeCookie {
+		sessionMode = sessionModeCookie
+	}
+	setOIDCCookies(c, auth.HashOpaqueToken(state), sessionMode, int(services.OIDCStateTTL.Seconds()))
+
+	logger.Success("API Request for SSO Login redirected to the provider.")
+	c.Redirect(http.StatusFound, url)
+}
+
+// Callback is the redirect URL registered at the provider.
+func (ctrl *ssoController) Callback(c *gin.Context) {
+	logger.Info("API Request for SSO Callback")
+	if providerErr := c.Query("error"); providerErr != "" {
+		logger.Warning("API Request for SSO Callback Stopped. Provider error: " + providerErr)
+		application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed",
+			fmt.Errorf("The provider returned an error: %s %s", providerErr, c.Query("error_description"))).WriteHTTPResponse(c)
+		return
+	}
+
+	stateHash, err := c.Cookie(oidcStateCookie)
+	sessionMode, _ := c.Cookie(oidcSessionModeCookie)
+	setOIDCCookies(c, "", "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(stateHash), []byte(auth.HashOpaqueToken(c.Query("state")))) != 1 {
+		logger.Warning("API Request for SSO Callback Stopped. Message: The state was not started by this browser")
+		application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed",
+			fmt.Errorf("Invalid or expired login state")).WriteHTTPResponse(c)
+		return
+	}
+
+	accessToken, refreshToken, sub, mfaToken, appErr := ctrl.authSvc.SSOLogin(c.Param("provider"), c.Query("code"), c.Query("state"), clientInfo(c, ""))
+	if appErr != nil {
+		logger.Danger("API Request for SSO Callback Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	if mfaToken != "" {
+		logger.Success("API Request for SSO Callback waiting for MFA verification.")
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "MFA verification required.", "result": gin.H{"mfa_required": true,
+			"mfa_token": mfaToken, "sub": sub}})
+		return
+	}
+
+	cookieMode := wantsCookieSession(c) || sessionMode == sessionModeCookie
+	result, appErr := withRefreshToken(c, cookieMode, gin.H{"access_token": accessToken, "sub": sub}, refreshToken)
+	if appErr != nil {
+		logger.Danger("API Request for SSO Callback Stopped")
+		appErr.WriteHTTPResponse(c)
+		return
+	}
+
+	logger.Success("API Request for SSO Callback success.")
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login is successfull.", "result": result})
+}
+
+// setOIDCCookies sets the cookies of a started login, or clears them with a
+// negative maxAge. They use SameSite=Lax, since the provider redirects back
+// from another site.
+func setOIDCCookies(c *gin.Context, stateHash, sessionMode string, maxAge int) {
+	cfg := getRefreshCookieConfig()
+	for name, value := range map[string]string{oidcStateCookie: stateHash, oidcSessionModeCookie: sessionMode} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     oidcCookiePath,
+			Domain:   cfg.Domain,
+			MaxAge:   maxAge,
+			Secure:   cfg.Secure,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
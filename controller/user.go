@@ -12,7 +12,8 @@ import (
 )
 
 type userController struct {
-	svc services.UserService
+	svc           services.UserService
+	invitationSvc services.InvitationService
 }
 
 type UserController interface {
@@ -25,7 +26,8 @@ type UserController interface {
 
 func NewUserController() UserController {
 	return &userController{
-		svc: services.NewUserService(),
+		svc:           services.NewUserService(),
+		invitationSvc: services.NewInvitationService(),
 	}
 }
 
@@ -39,14 +41,15 @@ func (ctlr *userController) Create(c *gin.Context) {
 		return
 	}
 
-	user, appErr := ctlr.svc.Create(userDTO)
+	// The user is created inactive and activates the account through the invitation.
+	user, invitation, appErr := ctlr.invitationSvc.ForOrganization(c.GetUint("organizationID")).Invite(userDTO, actor(c))
 	if appErr != nil {
 		appErr.WriteHTTPResponse(c)
 		logger.Info("Create user api stopped")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User Created", "result": gin.H{"user": user}})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User Created. An invitation has been sent.", "result": gin.H{"user": user, "invitation": invitation}})
 	logger.Info("Create user api finished")
 
 }
@@ -61,7 +64,7 @@ func (ctrl *userController) Find(c *gin.Context) {
 		return
 	}
 
-	users, appErr := ctrl.svc.Find(*filter)
+	users, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).Find(*filter)
 	if appErr != nil {
 		appErr.WriteHTTPResponse(c)
 		logger.Info("Find users api stopped")
@@ -83,7 +86,7 @@ func (ctrl *userController) FindByID(c *gin.Context) {
 		return
 	}
 
-	user, appErr := ctrl.svc.FindByID(uint(id))
+	user, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).FindByID(uint(id))
 	if appErr != nil {
 		appErr.WriteHTTPResponse(c)
 		logger.Info("Find user by id api stopped")
@@ -113,7 +116,7 @@ func (ctrl *userController) UpdateByID(c *gin.Context) {
 		return
 	}
 
-	updateUser, appErr := ctrl.svc.UpdateByID(uint(id), userDTO)
+	updateUser, appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).UpdateByID(uint(id), userDTO, actor(c))
 	if appErr != nil {
 		appErr.WriteHTTPResponse(c)
 		logger.Info("Update user by id api stopped")
@@ -135,7 +138,7 @@ func (ctrl *userController) DeleteByID(c *gin.Context) {
 		return
 	}
 
-	appErr := ctrl.svc.DeleteByID(uint(id))
+	appErr := ctrl.svc.ForOrganization(c.GetUint("organizationID")).DeleteByID(uint(id), actor(c))
 	if appErr != nil {
 		appErr.WriteHTTPResponse(c)
 		logger.Info("Delete user by id api stopped")
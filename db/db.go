@@ -30,11 +30,65 @@ func Automigrate() {
 	if db == nil {
 		Get()
 	}
+
+	// Accounts created before email verification existed are treated as verified.
+	backfillEmailVerified := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+	// Accounts created before organizations existed are moved into one organization.
+	backfillOrganization := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "OrganizationID")
+	backfillMembers := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasTable(&models.OrganizationMember{})
+
 	db.AutoMigrate(
+		models.Organization{},
 		models.User{},
+		models.Branch{},
+		models.BranchInvoicePrefix{},
+		models.Customer{},
+		models.CustomerContact{},
+		models.CustomerAddress{},
+		models.OrganizationMember{},
 		models.RefreshToken{},
+		models.PasswordResetToken{},
+		models.SecurityEvent{},
+		models.APIKey{},
+		models.EmailVerificationToken{},
+		models.ExternalIdentity{},
+		models.Invitation{},
+		models.ImpersonationAuditLog{},
+		models.Role{},
+		models.AuditEvent{},
 	)
 
+	// Users reference roles by name, so the built-in roles keep their names and
+	// existing superadmin, admin and user accounts resolve to them.
+	for _, builtIn := range models.BuiltInRoles() {
+		role := builtIn
+		err := db.Where("name = ? AND built_in = ?", role.Name, true).
+			Assign(models.Role{Description: builtIn.Description, Permissions: builtIn.Permissions}).
+			FirstOrCreate(&role).Error
+		if err != nil {
+			logger.HighlightedDanger("failed to seed the built-in role " + role.Name + ":" + err.Error())
+		}
+	}
+
+	if backfillEmailVerified {
+		if err := db.Exec(`UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL`).Error; err != nil {
+			logger.HighlightedDanger("failed to run migration:" + err.Error())
+		}
+	}
+
+	if backfillOrganization {
+		backfillUserOrganization()
+	}
+	if backfillMembers {
+		// Users of a single organization keep their role in it, and its admins own it.
+		err := db.Exec(`INSERT INTO organization_members (organization_id, user_id, role, owner, created_at, updated_at)
+			SELECT organization_id, id, role, role = 'admin', NOW(), NOW() FROM users
+			WHERE organization_id IS NOT NULL AND deleted_at IS NULL ON CONFLICT DO NOTHING`).Error
+		if err != nil {
+			logger.HighlightedDanger("failed to run migration:" + err.Error())
+		}
+	}
+
 	passwordsTableCreateQuery := `
 	CREATE TABLE IF NOT EXISTS passwords (
 	    id BIGSERIAL PRIMARY KEY,
@@ -45,4 +99,63 @@ func Automigrate() {
 	if err := db.Exec(passwordsTableCreateQuery).Error; err != nil {
 		logger.HighlightedDanger("failed to run migration:" + err.Error())
 	}
+
+	passwordHistoryTableCreateQuery := `
+	CREATE TABLE IF NOT EXISTS password_history (
+	    id BIGSERIAL PRIMARY KEY,
+	    hash TEXT NOT NULL,
+	    user_id BIGINT NOT NULL,
+	    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id);`
+
+	if err := db.Exec(passwordHistoryTableCreateQuery).Error; err != nil {
+		logger.HighlightedDanger("failed to run migration:" + err.Error())
+	}
+
+	mfaSecretsTableCreateQuery := `
+	CREATE TABLE IF NOT EXISTS mfa_secrets (
+	    id BIGSERIAL PRIMARY KEY,
+	    secret TEXT NOT NULL,
+	    user_id BIGINT UNIQUE NOT NULL,
+	    confirmed_at TIMESTAMPTZ,
+	    last_used_step BIGINT NOT NULL DEFAULT 0
+	);`
+
+	if err := db.Exec(mfaSecretsTableCreateQuery).Error; err != nil {
+		logger.HighlightedDanger("failed to run migration:" + err.Error())
+	}
+
+	mfaRecoveryCodesTableCreateQuery := `
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+	    id BIGSERIAL PRIMARY KEY,
+	    hash TEXT NOT NULL,
+	    user_id BIGINT NOT NULL,
+	    used_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id_hash ON mfa_recovery_codes (user_id, hash);`
+
+	if err := db.Exec(mfaRecoveryCodesTableCreateQuery).Error; err != nil {
+		logger.HighlightedDanger("failed to run migration:" + err.Error())
+	}
+}
+
+// backfillUserOrganization creates the first organization and moves every user
+// except the superadmins, who administer the platform, into it.
+func backfillUserOrganization() {
+	name := os.Getenv("DEFAULT_ORGANIZATION_NAME")
+	if name == "" {
+		name = "Default organization"
+	}
+
+	organization := &models.Organization{Name: name}
+	if err := db.Create(organization).Error; err != nil {
+		logger.HighlightedDanger("failed to create the default organization:" + err.Error())
+		return
+	}
+
+	err := db.Exec(`UPDATE users SET organization_id = ? WHERE organization_id IS NULL AND role <> ?`, organization.ID, models.RoleSuperAdmin).Error
+	if err != nil {
+		logger.HighlightedDanger("failed to run migration:" + err.Error())
+	}
 }
@@ -12,6 +12,12 @@ import (
 
 var redisClient *redis.Client
 
+// IsRedisConfigured reports whether REDIS_ADDR is set. Features with an
+// in-memory fallback use it to decide which storage to use.
+func IsRedisConfigured() bool {
+	return os.Getenv("REDIS_ADDR") != ""
+}
+
 func GetRedis() *redis.Client {
 
 	if redisClient != nil {
@@ -35,7 +41,7 @@ func GetRedis() *redis.Client {
 }
 
 func SetRedisCache(key string, value interface{}, expTime time.Duration) error {
-	if redisClient != nil {
+	if redisClient == nil {
 		redisClient = GetRedis()
 	}
 
@@ -43,9 +49,17 @@ func SetRedisCache(key string, value interface{}, expTime time.Duration) error {
 }
 
 func GetFromRedisCache(key string) (string, error) {
-	if redisClient != nil {
+	if redisClient == nil {
 		redisClient = GetRedis()
 	}
 
 	return redisClient.Get(context.Background(), key).Result()
 }
+
+func DeleteFromRedisCache(keys ...string) error {
+	if redisClient == nil {
+		redisClient = GetRedis()
+	}
+
+	return redisClient.Del(context.Background(), keys...).Err()
+}
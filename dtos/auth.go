@@ -6,14 +6,78 @@ type SignupDTO struct {
 	Phone           string `json:"phone"`
 	Password        string `json:"password"`
 	ConfirmPassword string `json:"confirm_password"`
+	// OrganizationName names the organization created for the new user. It
+	// defaults to the name of the user.
+	OrganizationName string `json:"organization_name"`
 }
 
 type LoginDTO struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name"`
+}
+
+type PhoneOTPRequestDTO struct {
+	Phone string `json:"phone"`
+}
+
+type PhoneLoginDTO struct {
+	Phone      string `json:"phone"`
+	OTP        string `json:"otp"`
+	DeviceName string `json:"device_name"`
+}
+
+type MFALoginDTO struct {
+	MFAToken   string `json:"mfa_token"`
+	Code       string `json:"code"`
+	DeviceName string `json:"device_name"`
 }
 
 type RotateRefreshTokenDTO struct {
 	RefreshToken string `json:"refresh_token"`
 	UserID       uint   `json:"user_id"`
 }
+
+type LogoutDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationEmailDTO struct {
+	Email string `json:"email"`
+}
+
+// ClientInfo describes the device a session is started from.
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+	RequestID  string
+}
+
+// Actor describes who makes a change, for the audit log. ImpersonatorID is set
+// when the request was made with an impersonation token.
+type Actor struct {
+	UserID         uint
+	ImpersonatorID uint
+	OrganizationID uint
+	Client         ClientInfo
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordDTO struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+type ClearLoginLockoutDTO struct {
+	Subject string `json:"subject"`
+	Value   string `json:"value"`
+}
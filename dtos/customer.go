@@ -0,0 +1,29 @@
+package dtos
+
+type CustomerContactDTO struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Designation string `json:"designation"`
+	Primary     bool   `json:"primary"`
+}
+
+type CustomerAddressDTO struct {
+	Type    string     `json:"type"`
+	Address AddressDTO `json:"address"`
+	Default bool       `json:"default"`
+}
+
+// CustomerDTO creates or changes a customer. Contacts and addresses replace the
+// existing ones when given, and an empty GSTIN or PAN clears it.
+type CustomerDTO struct {
+	LegalName        string                `json:"legal_name"`
+	DisplayName      string                `json:"display_name"`
+	GSTIN            *string               `json:"gstin"`
+	PAN              *string               `json:"pan"`
+	PaymentTermsDays *int                  `json:"payment_terms_days"`
+	DefaultCurrency  string                `json:"default_currency"`
+	Notes            *string               `json:"notes"`
+	Contacts         *[]CustomerContactDTO `json:"contacts"`
+	Addresses        *[]CustomerAddressDTO `json:"addresses"`
+}
@@ -0,0 +1,5 @@
+package dtos
+
+type MFACodeDTO struct {
+	Code string `json:"code"`
+}
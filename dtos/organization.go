@@ -0,0 +1,36 @@
+package dtos
+
+type OrganizationDTO struct {
+	Name string `json:"name"`
+}
+
+// OrganizationMemberDTO adds a member by email or changes a member. Name and
+// phone are only used when the email does not belong to a user yet.
+type OrganizationMemberDTO struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+	Role  string `json:"role"`
+	Owner *bool  `json:"owner"`
+	// BranchIDs restricts the member to the branches. An empty list allows
+	// every branch, leaving it out keeps the branches unchanged.
+	BranchIDs *[]uint `json:"branch_ids"`
+}
+
+type AddressDTO struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+type BranchDTO struct {
+	Name                 string      `json:"name"`
+	Address              *AddressDTO `json:"address"`
+	GSTIN                string      `json:"gstin"`
+	InvoicePrefix        *string     `json:"invoice_prefix"`
+	InvoiceNumberPadding *int        `json:"invoice_number_padding"`
+	NextInvoiceNumber    *uint       `json:"next_invoice_number"`
+}
@@ -6,4 +6,38 @@ type UserDTO struct {
 	Phone  string `json:"phone"`
 	Role   string `json:"role"`
 	Status string `json:"status"`
+	// OrganizationID is only used by platform administrators creating a user.
+	// Everyone else creates users in their own organization.
+	OrganizationID *uint `json:"organization_id"`
+}
+
+// UpdateProfileDTO holds the fields a user may change on their own account.
+// The phone is a login credential, so changing it requires the current password.
+type UpdateProfileDTO struct {
+	Name            string `json:"name"`
+	Phone           string `json:"phone"`
+	CurrentPassword string `json:"current_password"`
+}
+
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	ConfirmPassword string `json:"confirm_password"`
+	DeviceName      string `json:"device_name"`
+}
+
+type AcceptInvitationDTO struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+type ImpersonateDTO struct {
+	Reason string `json:"reason"`
+}
+
+type RoleDTO struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
 }
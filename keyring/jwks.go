@@ -0,0 +1,57 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys in the JSON Web Key Set format (RFC 7517), so
+// other services can verify access tokens without the signing keys.
+func (k *Keyring) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	for kid, publicKey := range k.PublicKeys() {
+		switch typed := publicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: "RS256",
+				N:   encode(typed.N.Bytes()),
+				E:   encode(big.NewInt(int64(typed.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: "EdDSA",
+				Crv: "Ed25519",
+				X:   encode(typed),
+			})
+		}
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid > jwks.Keys[j].Kid })
+	return jwks
+}
@@ -0,0 +1,239 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"treeforms_billing/logger"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// defaultKID identifies the legacy HS256 key built from JWT_SIGNING_SECRET.
+const defaultKID = "default"
+
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	signingKey interface{}
+	verifyKey  interface{}
+}
+
+// Keyring holds the keys used to sign and verify access tokens. Every key is
+// identified by a kid, which is written to the token header, so tokens signed
+// by a previous key stay valid while it is still in the keyring.
+type Keyring struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var (
+	mu      sync.RWMutex
+	current *Keyring
+)
+
+// Get returns the loaded keyring, loading it on first use.
+func Get() *Keyring {
+	mu.RLock()
+	k := current
+	mu.RUnlock()
+	if k != nil {
+		return k
+	}
+
+	if err := Reload(); err != nil {
+		logger.HighlightedDanger("Unable to load the jwt keyring. Message: " + err.Error())
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Reload reads the keys again. When JWT_KEYS_DIR is set, every <kid>.pem file in
+// it is loaded; RSA keys sign with RS256 and Ed25519 keys with EdDSA, and files
+// holding only a public key are used for verification only. The signing key is
+// JWT_ACTIVE_KID, or the greatest kid when it is not set, so a new key can be
+// rolled out by adding its file and reloading. Without JWT_KEYS_DIR the HS256
+// JWT_SIGNING_SECRET is used. With JWT_KEYS_DIR a JWT_SIGNING_SECRET that is
+// still set only verifies the tokens issued before the switch, and can be
+// removed once they have expired. On error the previous keyring is kept.
+func Reload() error {
+	k, err := load()
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	current = k
+	mu.Unlock()
+
+	logger.Success("Loaded jwt keyring. Active key: " + k.active.kid + " (" + k.active.method.Alg() + ")")
+	return nil
+}
+
+func load() (*Keyring, error) {
+	k := &Keyring{keys: map[string]*signingKey{}}
+
+	secret := os.Getenv("JWT_SIGNING_SECRET")
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if secret == "" {
+			return nil, fmt.Errorf("neither JWT_KEYS_DIR nor JWT_SIGNING_SECRET is set")
+		}
+		k.active = &signingKey{
+			kid:        defaultKID,
+			method:     jwt.SigningMethodHS256,
+			signingKey: []byte(secret),
+			verifyKey:  []byte(secret),
+		}
+		k.keys[defaultKID] = k.active
+		return k, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := loadPEM(kid, file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the key %s: %w", kid, err)
+		}
+		k.keys[kid] = key
+	}
+
+	if _, ok := k.keys[defaultKID]; !ok && secret != "" {
+		k.keys[defaultKID] = &signingKey{kid: defaultKID, method: jwt.SigningMethodHS256, verifyKey: []byte(secret)}
+	}
+
+	activeKID := os.Getenv("JWT_ACTIVE_KID")
+	if activeKID == "" {
+		kids := make([]string, 0, len(k.keys))
+		for kid, key := range k.keys {
+			if key.signingKey != nil {
+				kids = append(kids, kid)
+			}
+		}
+		sort.Strings(kids)
+		if len(kids) > 0 {
+			activeKID = kids[len(kids)-1]
+		}
+	}
+
+	active, ok := k.keys[activeKID]
+	if !ok || active.signingKey == nil {
+		return nil, fmt.Errorf("no private key found for the active kid %q in %s", activeKID, dir)
+	}
+	k.active = active
+
+	return k, nil
+}
+
+func loadPEM(kid, file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported pem block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch typed := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.signingKey, key.verifyKey = jwt.SigningMethodRS256, typed, &typed.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.verifyKey = jwt.SigningMethodRS256, typed
+	case ed25519.PrivateKey:
+		key.method, key.signingKey, key.verifyKey = jwt.SigningMethodEdDSA, typed, typed.Public()
+	case ed25519.PublicKey:
+		key.method, key.verifyKey = jwt.SigningMethodEdDSA, typed
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	return key, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.kid
+	return token.SignedString(k.active.signingKey)
+}
+
+// Keyfunc resolves the verification key from the kid header for jwt.Parse.
+// Tokens without a kid were signed before the keyring existed and are checked
+// against the legacy HS256 key, which is kept for verification while
+// JWT_SIGNING_SECRET is set.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = defaultKID
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for the key %q", token.Method.Alg(), kid)
+	}
+
+	return key.verifyKey, nil
+}
+
+// ValidMethods lists the algorithms of the keys in the keyring.
+func (k *Keyring) ValidMethods() []string {
+	seen := map[string]bool{}
+	methods := []string{}
+	for _, key := range k.keys {
+		if !seen[key.method.Alg()] {
+			seen[key.method.Alg()] = true
+			methods = append(methods, key.method.Alg())
+		}
+	}
+	return methods
+}
+
+// PublicKeys returns the verification keys that can be published, i.e. all
+// asymmetric keys, keyed by kid.
+func (k *Keyring) PublicKeys() map[string]crypto.PublicKey {
+	publicKeys := map[string]crypto.PublicKey{}
+	for kid, key := range k.keys {
+		if _, symmetric := key.verifyKey.([]byte); symmetric {
+			continue
+		}
+		publicKeys[kid] = key.verifyKey
+	}
+	return publicKeys
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"os"
+	"strings"
+	"treeforms_billing/logger"
+)
+
+// Mailer delivers plain text emails to a single recipient.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// New returns the mailer selected by MAILER_DRIVER. "smtp" sends real emails,
+// anything else writes them to the local outbox directory.
+func New() Mailer {
+	switch strings.ToLower(os.Getenv("MAILER_DRIVER")) {
+	case "smtp":
+		return NewSMTPMailer()
+	default:
+		logger.Info("Using outbox mailer. Emails will be written to the local outbox directory.")
+		return NewOutboxMailer()
+	}
+}
+
+func sender() string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	return "no-reply@treeforms.local"
+}
+
+func composeMessage(from, to, subject, body string) []byte {
+	var msg strings.Builder
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+	return []byte(msg.String())
+}
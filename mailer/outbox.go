@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"treeforms_billing/logger"
+)
+
+type outboxMailer struct {
+	dir  string
+	from string
+}
+
+// NewOutboxMailer returns a mailer that writes every email as a .eml file into
+// MAIL_OUTBOX_DIR (default "outbox") instead of sending it. Meant for local use.
+func NewOutboxMailer() Mailer {
+	dir := os.Getenv("MAIL_OUTBOX_DIR")
+	if dir == "" {
+		dir = "outbox"
+	}
+
+	return &outboxMailer{
+		dir:  dir,
+		from: sender(),
+	}
+}
+
+func (m *outboxMailer) Send(to, subject, body string) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		logger.HighlightedDanger("Unable to create outbox directory. Message: " + err.Error())
+		return fmt.Errorf("unable to create outbox directory: %w", err)
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(to)
+	fileName := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + recipient + ".eml"
+	path := filepath.Join(m.dir, fileName)
+
+	if err := os.WriteFile(path, composeMessage(m.from, to, subject, body), 0o600); err != nil {
+		logger.HighlightedDanger("Unable to write email to the outbox. Message: " + err.Error())
+		return fmt.Errorf("unable to write email to the outbox: %w", err)
+	}
+
+	logger.Success("Email for " + to + " written to " + path)
+	return nil
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"treeforms_billing/logger"
+)
+
+type smtpMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer() Mailer {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return &smtpMailer{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     port,
+		username: os.Getenv("SMTP_USER"),
+		password: os.Getenv("SMTP_PASS"),
+		from:     sender(),
+	}
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	addr := net.JoinHostPort(m.host, m.port)
+	if err := smtp.SendMail(addr, auth, m.from, []string{to}, composeMessage(m.from, to, subject, body)); err != nil {
+		logger.HighlightedDanger("Sending email through SMTP failed. Message: " + err.Error())
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+
+	logger.Success("Email sent to " + to)
+	return nil
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
+	"treeforms_billing/auth"
 	"treeforms_billing/db"
+	"treeforms_billing/keyring"
 	"treeforms_billing/logger"
 	"treeforms_billing/routes"
 
@@ -18,6 +22,31 @@ func main() {
 		return
 	}
 
+	// Load the jwt signing keys. SIGHUP reloads them to rotate keys without a restart.
+	if err := keyring.Reload(); err != nil {
+		logger.HighlightedDanger("Error while loading jwt keys. Message: " + err.Error())
+		return
+	}
+	reloadKeyringOnSIGHUP()
+
+	// Load the key which encrypts the TOTP secrets.
+	if err := auth.LoadMFAEncryptionKey(); err != nil {
+		logger.HighlightedDanger("Error while loading the mfa encryption key. Message: " + err.Error())
+		return
+	}
+
+	// Check the parameters of the password hasher.
+	if err := auth.CheckHasher(); err != nil {
+		logger.HighlightedDanger("Error in the password hasher configuration. Message: " + err.Error())
+		return
+	}
+
+	// Load the breached password list of the password policy.
+	if err := auth.LoadBreachedPasswords(); err != nil {
+		logger.HighlightedDanger("Error while loading the breached password list. Message: " + err.Error())
+		return
+	}
+
 	// Automigrate DB
 	db.Automigrate()
 
@@ -34,3 +63,17 @@ func main() {
 
 	r.Run()
 }
+
+func reloadKeyringOnSIGHUP() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			logger.Info("SIGHUP received. Reloading jwt keys.")
+			if err := keyring.Reload(); err != nil {
+				logger.HighlightedDanger("Reloading jwt keys failed, keeping the current keys. Message: " + err.Error())
+			}
+		}
+	}()
+}
@@ -2,18 +2,31 @@ package middlewares
 
 import (
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"treeforms_billing/application_types"
+	"treeforms_billing/keyring"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
 	"treeforms_billing/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
+const (
+	AuthMethodAccessToken = "access_token"
+	AuthMethodAPIKey      = "api_key"
+)
+
 type authenticationMiddleware struct {
 	authenticationSvc services.AuthenticationService
 	userSvc           services.UserService
+	revocationSvc     services.TokenRevocationService
+	apiKeySvc         services.APIKeyService
+	impersonationSvc  services.ImpersonationService
+	roleSvc           services.RoleService
+	memberSvc         services.OrganizationMemberService
 }
 
 type AuthenticationMiddleware interface {
@@ -24,10 +37,22 @@ func NewAuthenticationMiddleware() AuthenticationMiddleware {
 	return &authenticationMiddleware{
 		authenticationSvc: services.NewAuthenticationSevice(),
 		userSvc:           services.NewUserService(),
+		revocationSvc:     services.NewTokenRevocationService(),
+		apiKeySvc:         services.NewAPIKeyService(),
+		impersonationSvc:  services.NewImpersonationService(),
+		roleSvc:           services.NewRoleService(),
+		memberSvc:         services.NewOrganizationMemberService(),
 	}
 }
 
+// ValidateAccessToken authenticates the request either with a Bearer access
+// token or, for machine clients, with an API key in the X-API-Key header.
 func (mw *authenticationMiddleware) ValidateAccessToken(c *gin.Context) {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		mw.validateAPIKey(c, apiKey)
+		return
+	}
+
 	authHeader := c.GetHeader("Authorization")
 
 	if authHeader == "" {
@@ -42,18 +67,112 @@ func (mw *authenticationMiddleware) ValidateAccessToken(c *gin.Context) {
 	}
 
 	claims := application_types.AccessToken{}
-	token, err := jwt.ParseWithClaims(parts[1], &claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SIGNING_SECRET")), nil
-	})
+	keys := keyring.Get()
+	token, err := jwt.ParseWithClaims(parts[1], &claims, keys.Keyfunc, jwt.WithValidMethods(keys.ValidMethods()))
 
 	if err != nil || !token.Valid {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "failed", "message": "Invalid Token"})
 		return
 	}
 
-	c.Set("userID", claims.ID)
+	revoked, err := mw.revocationSvc.IsRevoked(claims.ID)
+	if err != nil {
+		// Fail open like the login throttling; the token still expires in minutes.
+		logger.HighlightedDanger("Unable to check the access token denylist. Message: " + err.Error())
+	} else if revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "failed", "message": "Token has been revoked"})
+		return
+	}
+
+	if services.IsUserStatusCheckEnabled() {
+		active, err := mw.userSvc.IsActive(claims.UserID)
+		if err != nil {
+			logger.HighlightedDanger("Unable to check the user status. Message: " + err.Error())
+		} else if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "failed", "message": "User is inactive"})
+			return
+		}
+	}
+
+	if !mw.setPermissions(c, claims.Role, claims.OrganizationID) {
+		return
+	}
+
+	c.Set("userID", claims.UserID)
 	c.Set("userRole", claims.Role)
 	c.Set("userName", claims.Name)
+	c.Set("sessionID", claims.SessionID)
+	c.Set("organizationID", claims.OrganizationID)
+	c.Set("branchID", claims.BranchID)
+	c.Set("authMethod", AuthMethodAccessToken)
+	c.Set("accessToken", &claims)
+
+	if claims.Act == nil {
+		c.Next()
+		return
+	}
+
+	// Every request made while impersonating ends up in the audit log.
+	c.Set("impersonatorID", claims.Act.UserID)
+	c.Header("X-Impersonated-By", strconv.FormatUint(uint64(claims.Act.UserID), 10))
+	c.Next()
+
+	mw.impersonationSvc.RecordRequest(&models.ImpersonationAuditLog{
+		ImpersonatorID: claims.Act.UserID,
+		UserID:         claims.UserID,
+		TokenID:        claims.ID,
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.Path,
+		Status:         c.Writer.Status(),
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+	})
+}
+
+func (mw *authenticationMiddleware) validateAPIKey(c *gin.Context, key string) {
+	apiKey, user, appErr := mw.apiKeySvc.Authenticate(key)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		c.Abort()
+		return
+	}
+
+	// API keys act in the organization they were created in, as long as the user is a member of it.
+	user, appErr = mw.memberSvc.ResolveUserIn(user, apiKey.OrganizationIDOrZero(), apiKey.BranchIDOrZero())
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		c.Abort()
+		return
+	}
+
+	if !mw.setPermissions(c, user.Role, user.OrganizationIDOrZero()) {
+		return
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+	c.Set("userName", user.Name)
+	c.Set("organizationID", user.OrganizationIDOrZero())
+	c.Set("branchID", user.BranchID)
+	c.Set("apiKey", apiKey)
+	c.Set("authMethod", AuthMethodAPIKey)
 
 	c.Next()
 }
+
+// setPermissions resolves the effective permissions of the role in the
+// organization for RequirePermission. The role is resolved on every request, so
+// changes to its permissions apply without waiting for the access token to
+// expire.
+func (mw *authenticationMiddleware) setPermissions(c *gin.Context, role string, organizationID uint) bool {
+	permissions, appErr := mw.roleSvc.ForOrganization(organizationID).Permissions(role)
+	if appErr != nil {
+		logger.Danger("Unable to resolve the permissions of the role '" + role + "'. Message: " + appErr.GetErrorMessage())
+		appErr.WriteHTTPResponse(c)
+		c.Abort()
+		return false
+	}
+
+	c.Set("permissions", permissions)
+	return true
+}
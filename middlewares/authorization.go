@@ -0,0 +1,201 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"treeforms_billing/application_types"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+	"treeforms_billing/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type authorizationMiddleware struct {
+	userSvc   services.UserService
+	roleSvc   services.RoleService
+	memberSvc services.OrganizationMemberService
+}
+
+type AuthorizationMiddleware interface {
+	RequirePermission(permission string) gin.HandlerFunc
+	RequireScope(scope string) gin.HandlerFunc
+	RequireUserSession(c *gin.Context)
+	RequireOrganization(c *gin.Context)
+	RequireOrganizationOwner(c *gin.Context)
+	AuthorizeUserManagement(c *gin.Context)
+	BlockImpersonation(c *gin.Context)
+}
+
+func NewAuthorizationMiddleware() AuthorizationMiddleware {
+	return &authorizationMiddleware{
+		userSvc:   services.NewUserService(),
+		roleSvc:   services.NewRoleService(),
+		memberSvc: services.NewOrganizationMemberService(),
+	}
+}
+
+// RequirePermission allows the request only when the role of the user grants
+// the permission. It must run after ValidateAccessToken.
+func (mw *authorizationMiddleware) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !models.HasPermission(c.GetStringSlice("permissions"), permission) {
+			logger.Warning("Access denied for the role '" + c.GetString("userRole") + "' without the permission '" + permission + "' on " + c.Request.Method + " " + c.FullPath())
+			abortForbidden(c, fmt.Errorf("Your role is missing the permission %s", permission))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope limits requests made with an API key to keys holding the scope.
+// Requests made with an access token are not affected.
+func (mw *authorizationMiddleware) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodAPIKey {
+			c.Next()
+			return
+		}
+
+		apiKey, ok := c.MustGet("apiKey").(*models.APIKey)
+		if !ok || !apiKey.HasScope(scope) {
+			logger.Warning("Access denied for an api key without the scope '" + scope + "'")
+			abortForbidden(c, fmt.Errorf("API key is missing the scope %s", scope))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUserSession rejects API keys on routes meant for a logged in person,
+// such as managing their own sessions, MFA or API keys.
+func (mw *authorizationMiddleware) RequireUserSession(c *gin.Context) {
+	if c.GetString("authMethod") != AuthMethodAccessToken {
+		abortForbidden(c, fmt.Errorf("This resource is not available to API keys"))
+		return
+	}
+
+	c.Next()
+}
+
+// RequireOrganization rejects requests outside of an organization on routes
+// whose data belongs to one. Platform administrators, whose role grants every
+// permission, may work across organizations.
+func (mw *authorizationMiddleware) RequireOrganization(c *gin.Context) {
+	if c.GetUint("organizationID") == 0 && !models.HasPermission(c.GetStringSlice("permissions"), models.PermissionAll) {
+		logger.Warning("Blocked " + c.Request.Method + " " + c.FullPath() + " without an organization")
+		abortForbidden(c, fmt.Errorf("You are not a member of any organization"))
+		return
+	}
+
+	c.Next()
+}
+
+// RequireOrganizationOwner allows the request only for owners of the
+// organization the request acts in. Ownership is checked on every request, so
+// it does not wait for the access token to expire.
+func (mw *authorizationMiddleware) RequireOrganizationOwner(c *gin.Context) {
+	organizationID := c.GetUint("organizationID")
+	if organizationID == 0 {
+		logger.Warning("Blocked " + c.Request.Method + " " + c.FullPath() + " without an organization")
+		abortForbidden(c, fmt.Errorf("You are not a member of any organization"))
+		return
+	}
+
+	member, appErr := mw.memberSvc.FindMember(organizationID, c.GetUint("userID"))
+	if appErr != nil && appErr.GetHTTPStatusCode() != http.StatusNotFound {
+		appErr.WriteHTTPResponse(c)
+		c.Abort()
+		return
+	}
+	if member == nil || !member.Owner {
+		logger.Warning("Access denied for a non owner on " + c.Request.Method + " " + c.FullPath())
+		abortForbidden(c, fmt.Errorf("Only owners of the organization can manage its members"))
+		return
+	}
+
+	c.Next()
+}
+
+// BlockImpersonation rejects sensitive actions, such as changing the password or
+// MFA, while another user is impersonating the user.
+func (mw *authorizationMiddleware) BlockImpersonation(c *gin.Context) {
+	if c.GetUint("impersonatorID") != 0 {
+		logger.Warning("Blocked " + c.Request.Method + " " + c.FullPath() + " while impersonating")
+		abortForbidden(c, fmt.Errorf("This action is not allowed while impersonating a user"))
+		return
+	}
+
+	c.Next()
+}
+
+// AuthorizeUserManagement enforces who may manage whom: a role may only manage
+// accounts holding roles with fewer permissions, see models.CanManageRole.
+func (mw *authorizationMiddleware) AuthorizeUserManagement(c *gin.Context) {
+	actorRole := c.GetString("userRole")
+
+	if idStr := c.Param("id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 0)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid User ID", "result": gin.H{"error": err.Error()}})
+			return
+		}
+
+		target, appErr := mw.userSvc.ForOrganization(c.GetUint("organizationID")).FindByID(uint(id))
+		if appErr != nil {
+			appErr.WriteHTTPResponse(c)
+			c.Abort()
+			return
+		}
+
+		canManage := mw.canManageRole(c, actorRole, target.Role)
+		if c.IsAborted() {
+			return
+		}
+		if !canManage {
+			logger.Warning("Access denied for the role '" + actorRole + "' to manage a user with the role '" + target.Role + "'")
+			abortForbidden(c, fmt.Errorf("Your role cannot manage %s accounts", target.Role))
+			return
+		}
+	}
+
+	if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPatch {
+		userDTO := &dtos.UserDTO{}
+		if err := c.ShouldBindBodyWithJSON(userDTO); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "failed", "message": "Invalid Request Body", "result": gin.H{"error": err.Error()}})
+			return
+		}
+
+		canAssign := userDTO.Role == "" || mw.canManageRole(c, actorRole, userDTO.Role)
+		if c.IsAborted() {
+			return
+		}
+		if !canAssign {
+			logger.Warning("Access denied for the role '" + actorRole + "' to assign the role '" + userDTO.Role + "'")
+			abortForbidden(c, fmt.Errorf("Your role cannot assign the %s role", userDTO.Role))
+			return
+		}
+	}
+
+	c.Next()
+}
+
+// canManageRole aborts with the error of the role service when a role cannot be resolved.
+func (mw *authorizationMiddleware) canManageRole(c *gin.Context, actorRole, targetRole string) bool {
+	canManage, appErr := mw.roleSvc.ForOrganization(c.GetUint("organizationID")).CanManageRole(actorRole, targetRole)
+	if appErr != nil {
+		appErr.WriteHTTPResponse(c)
+		c.Abort()
+		return false
+	}
+	return canManage
+}
+
+func abortForbidden(c *gin.Context, err error) {
+	application_types.NewApplicationError(false, http.StatusForbidden, "Access denied", err).WriteHTTPResponse(c)
+	c.Abort()
+}
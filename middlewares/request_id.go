@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"regexp"
+	"treeforms_billing/auth"
+	"treeforms_billing/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Request ids from a proxy are kept only when they are short and plain, since
+// they end up in the audit log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDMiddleware struct{}
+
+type RequestIDMiddleware interface {
+	AssignRequestID(c *gin.Context)
+}
+
+func NewRequestIDMiddleware() RequestIDMiddleware {
+	return &requestIDMiddleware{}
+}
+
+// AssignRequestID sets the requestID of the request from the X-Request-ID
+// header, or a new one, and echoes it in the response.
+func (mw *requestIDMiddleware) AssignRequestID(c *gin.Context) {
+	requestID := c.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		var err error
+		requestID, err = auth.NewRandomID()
+		if err != nil {
+			logger.Warning("Unable to generate a request id. Message: " + err.Error())
+			c.Next()
+			return
+		}
+	}
+
+	c.Set("requestID", requestID)
+	c.Header(requestIDHeader, requestID)
+	c.Next()
+}
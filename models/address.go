@@ -0,0 +1,11 @@
+package models
+
+// Address is a postal address, embedded into the rows which have one.
+type Address struct {
+	Line1      string `json:"line1" validate:"required,max=200"`
+	Line2      string `json:"line2" validate:"max=200"`
+	City       string `json:"city" validate:"required,max=100"`
+	State      string `json:"state" validate:"required,max=100"`
+	PostalCode string `json:"postal_code" validate:"required,max=20"`
+	Country    string `json:"country" validate:"max=100"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ScopeUsersRead      = "users:read"
+	ScopeUsersWrite     = "users:write"
+	ScopeCustomersRead  = "customers:read"
+	ScopeCustomersWrite = "customers:write"
+)
+
+// APIKeyScopes lists every scope that can be granted to an API key.
+var APIKeyScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeCustomersRead, ScopeCustomersWrite}
+
+type APIKey struct {
+	gorm.Model
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" validate:"required" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	KeyHash    string     `json:"-" gorm:"not null;unique"`
+	Scopes     []string   `json:"scopes" validate:"required,min=1,dive,required" gorm:"serializer:json;not null"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+
+	// The organization and branch the key acts in, fixed when it is created.
+	OrganizationID *uint `json:"organization_id" gorm:"index"`
+	BranchID       *uint `json:"branch_id"`
+}
+
+func (k *APIKey) OrganizationIDOrZero() uint {
+	if k.OrganizationID == nil {
+		return 0
+	}
+	return *k.OrganizationID
+}
+
+func (k *APIKey) BranchIDOrZero() uint {
+	if k.BranchID == nil {
+		return 0
+	}
+	return *k.BranchID
+}
+
+func (k *APIKey) ValidateFields() error {
+	return validate.Struct(k)
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || k.ExpiresAt.After(time.Now())
+}
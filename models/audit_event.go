@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+const (
+	AuditActionLoginSucceeded       = "login_succeeded"
+	AuditActionLoginFailed          = "login_failed"
+	AuditActionLoginLockedOut       = "login_locked_out"
+	AuditActionRefreshTokenRotated  = "refresh_token_rotated"
+	AuditActionRefreshTokenRejected = "refresh_token_rejected"
+	AuditActionPasswordChanged      = "password_changed"
+	AuditActionUserCreated          = "user_created"
+	AuditActionUserUpdated          = "user_updated"
+	AuditActionUserDeleted          = "user_deleted"
+	AuditActionMemberInvited        = "member_invited"
+	AuditActionMemberAdded          = "member_added"
+	AuditActionMemberUpdated        = "member_updated"
+	AuditActionMemberRemoved        = "member_removed"
+	AuditActionOrganizationSwitched = "organization_switched"
+	AuditActionBranchSwitched       = "branch_switched"
+	AuditActionCustomerCreated      = "customer_created"
+	AuditActionCustomerUpdated      = "customer_updated"
+	AuditActionCustomerDeleted      = "customer_deleted"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+const (
+	AuditTargetUser     = "user"
+	AuditTargetCustomer = "customer"
+)
+
+// AuditChange is the value of a field before and after an update.
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditEvent records who did what. Events are append only, so there is no
+// updated or deleted time.
+type AuditEvent struct {
+	ID             uint                   `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time              `json:"created_at" gorm:"index"`
+	Action         string                 `json:"action" gorm:"not null;index"`
+	Outcome        string                 `json:"outcome" gorm:"not null;index"`
+	ActorID        *uint                  `json:"actor_id" gorm:"index"`
+	ImpersonatorID *uint                  `json:"impersonator_id"`
+	OrganizationID *uint                  `json:"organization_id" gorm:"index"`
+	TargetType     string                 `json:"target_type" gorm:"index:idx_audit_event_target"`
+	TargetID       *uint                  `json:"target_id" gorm:"index:idx_audit_event_target"`
+	IPAddress      string                 `json:"ip_address"`
+	UserAgent      string                 `json:"user_agent"`
+	RequestID      string                 `json:"request_id" gorm:"index"`
+	Details        string                 `json:"details"`
+	Changes        map[string]AuditChange `json:"changes,omitempty" gorm:"serializer:json"`
+}
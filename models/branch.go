@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Branch is an outlet of an organization. Every branch bills separately with
+// its own GSTIN and invoice numbering series.
+type Branch struct {
+	gorm.Model
+	OrganizationID       uint    `json:"organization_id" gorm:"not null;index"`
+	Name                 string  `json:"name" validate:"required,max=150" gorm:"not null"`
+	Address              Address `json:"address" gorm:"embedded;embeddedPrefix:address_"`
+	GSTIN                string  `json:"gstin" validate:"omitempty,gstin" gorm:"index"`
+	InvoicePrefix        string  `json:"invoice_prefix" validate:"max=20"`
+	InvoiceNumberPadding int     `json:"invoice_number_padding" validate:"min=0,max=12" gorm:"not null;default:0"`
+	NextInvoiceNumber    uint    `json:"next_invoice_number" validate:"min=1" gorm:"not null;default:1"`
+}
+
+func (b *Branch) ValidateFields() error {
+	return validate.Struct(b)
+}
+
+// BranchInvoicePrefix records every invoice prefix the branches of an
+// organization have held. A prefix is never taken again, not even after its
+// branch moved on or was deleted, so that invoice numbers never repeat.
+type BranchInvoicePrefix struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_branch_invoice_prefix"`
+	Prefix         string    `json:"prefix" gorm:"not null;uniqueIndex:idx_branch_invoice_prefix"`
+	BranchID       uint      `json:"branch_id" gorm:"not null;index"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ScopeBranch limits a query on billing data to the rows of the branch and the
+// rows without a branch, which are shared by every branch. A zero id leaves the
+// query unscoped, which is meant for users allowed in every branch of the
+// organization.
+func ScopeBranch(branchID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if branchID == 0 {
+			return db
+		}
+		return db.Where("(branch_id = ? OR branch_id IS NULL)", branchID)
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CustomerAddressBilling  = "billing"
+	CustomerAddressShipping = "shipping"
+)
+
+const DefaultCurrency = "INR"
+
+// Customer is a business or person an organization bills. The PAN of a
+// customer with a GSTIN is the one inside the GSTIN. Customers created while
+// acting in a branch belong to it, the others are shared by every branch.
+type Customer struct {
+	gorm.Model
+	OrganizationID   uint               `json:"organization_id" gorm:"not null;index"`
+	BranchID         *uint              `json:"branch_id" gorm:"index"`
+	LegalName        string             `json:"legal_name" validate:"required,max=200" gorm:"not null"`
+	DisplayName      string             `json:"display_name" validate:"required,max=200" gorm:"not null"`
+	GSTIN            string             `json:"gstin" validate:"omitempty,gstin" gorm:"index"`
+	PAN              string             `json:"pan" validate:"omitempty,pan" gorm:"index"`
+	PaymentTermsDays int                `json:"payment_terms_days" validate:"min=0,max=365" gorm:"not null;default:0"`
+	DefaultCurrency  string             `json:"default_currency" validate:"required,len=3,alpha" gorm:"not null"`
+	Notes            string             `json:"notes" validate:"max=2000"`
+	Contacts         []*CustomerContact `json:"contacts" validate:"dive"`
+	Addresses        []*CustomerAddress `json:"addresses" validate:"dive"`
+}
+
+// CustomerContact is a person to reach at the customer. Contacts are replaced
+// as a whole when the customer is updated.
+type CustomerContact struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	CustomerID  uint   `json:"-" gorm:"not null;index"`
+	Name        string `json:"name" validate:"required,max=150" gorm:"not null"`
+	Email       string `json:"email" validate:"omitempty,email,max=254"`
+	Phone       string `json:"phone" validate:"max=20"`
+	Designation string `json:"designation" validate:"max=100"`
+	Primary     bool   `json:"primary" gorm:"not null;default:false"`
+}
+
+// CustomerAddress is a billing or shipping address of the customer. Addresses
+// are replaced as a whole when the customer is updated.
+type CustomerAddress struct {
+	ID         uint    `json:"id" gorm:"primarykey"`
+	CustomerID uint    `json:"-" gorm:"not null;index"`
+	Type       string  `json:"type" validate:"required,oneof=billing shipping" gorm:"not null"`
+	Address    Address `json:"address" gorm:"embedded;embeddedPrefix:address_"`
+	Default    bool    `json:"default" gorm:"not null;default:false"`
+}
+
+// ValidateFields also allows at most one primary contact, one default billing
+// address and one default shipping address, and requires the PAN to match the
+// GSTIN.
+func (c *Customer) ValidateFields() error {
+	if err := validate.Struct(c); err != nil {
+		return err
+	}
+
+	if c.GSTIN != "" && c.PAN != "" && c.GSTIN[2:12] != c.PAN {
+		return fmt.Errorf("The PAN %s does not match the GSTIN %s", c.PAN, c.GSTIN)
+	}
+
+	primaryContacts := 0
+	for _, contact := range c.Contacts {
+		if contact.Primary {
+			primaryContacts++
+		}
+	}
+	if primaryContacts > 1 {
+		return fmt.Errorf("Only one contact can be the primary contact")
+	}
+
+	defaults := map[string]int{}
+	for _, address := range c.Addresses {
+		if address.Default {
+			defaults[address.Type]++
+		}
+	}
+	for addressType, count := range defaults {
+		if count > 1 {
+			return fmt.Errorf("Only one %s address can be the default", addressType)
+		}
+	}
+
+	return nil
+}
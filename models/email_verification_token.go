@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmailVerificationToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	Email     string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// ExternalIdentity links a user to the subject of an OpenID Connect provider.
+type ExternalIdentity struct {
+	gorm.Model
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	Provider string `json:"provider" gorm:"not null;uniqueIndex:idx_external_identity_subject"`
+	Subject  string `json:"-" gorm:"not null;uniqueIndex:idx_external_identity_subject"`
+	Email    string `json:"email"`
+}
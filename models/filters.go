@@ -1,9 +1,73 @@
 package models
 
+import "time"
+
 type UserFilter struct {
 	Name   string `json:"name"`
 	Email  string `json:"email"`
 	Phone  string `json:"phone"`
 	Role   string `json:"role"`
-	Status string `json:"role"`
+	Status string `json:"status"`
+}
+
+const (
+	AuditEventDefaultPageSize = 50
+	AuditEventMaxPageSize     = 200
+)
+
+// AuditEventFilter filters the audit events. Page starts at 1.
+type AuditEventFilter struct {
+	// OrganizationID is set from the access token, not from the query.
+	OrganizationID uint       `form:"-"`
+	Action         string     `form:"action"`
+	Outcome        string     `form:"outcome"`
+	ActorID        uint       `form:"actor_id"`
+	TargetType     string     `form:"target_type"`
+	TargetID       uint       `form:"target_id"`
+	IPAddress      string     `form:"ip_address"`
+	RequestID      string     `form:"request_id"`
+	From           *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To             *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page           int        `form:"page"`
+	PageSize       int        `form:"page_size"`
+}
+
+// Normalize applies the default page and page size and caps the page size.
+func (f *AuditEventFilter) Normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.PageSize < 1 {
+		f.PageSize = AuditEventDefaultPageSize
+	}
+	if f.PageSize > AuditEventMaxPageSize {
+		f.PageSize = AuditEventMaxPageSize
+	}
+}
+
+const (
+	CustomerDefaultPageSize = 50
+	CustomerMaxPageSize     = 200
+)
+
+// CustomerFilter searches the customers. Search matches the names, GSTIN, PAN
+// and the contacts. Page starts at 1.
+type CustomerFilter struct {
+	Search   string `form:"search"`
+	GSTIN    string `form:"gstin"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// Normalize applies the default page and page size and caps the page size.
+func (f *CustomerFilter) Normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.PageSize < 1 {
+		f.PageSize = CustomerDefaultPageSize
+	}
+	if f.PageSize > CustomerMaxPageSize {
+		f.PageSize = CustomerMaxPageSize
+	}
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+var (
+	gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+	panPattern   = regexp.MustCompile(`^[A-Z]{3}[ABCFGHJLPTE][A-Z][0-9]{4}[A-Z]$`)
+)
+
+const gstinCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func init() {
+	validate.RegisterValidation("gstin", func(fl validator.FieldLevel) bool { return IsValidGSTIN(fl.Field().String()) })
+	validate.RegisterValidation("pan", func(fl validator.FieldLevel) bool { return IsValidPAN(fl.Field().String()) })
+}
+
+// IsValidGSTIN reports whether the GSTIN is well formed, carries a valid PAN
+// and ends with the check character of the first 14 characters.
+func IsValidGSTIN(gstin string) bool {
+	if !gstinPattern.MatchString(gstin) || !IsValidPAN(gstin[2:12]) {
+		return false
+	}
+
+	sum := 0
+	for i, char := range gstin[:14] {
+		product := strings.IndexRune(gstinCharset, char) * (i%2 + 1)
+		sum += product/len(gstinCharset) + product%len(gstinCharset)
+	}
+	check := (len(gstinCharset) - sum%len(gstinCharset)) % len(gstinCharset)
+	return gstin[14] == gstinCharset[check]
+}
+
+// IsValidPAN reports whether the PAN is well formed with a known holder type
+// as its fourth character.
+func IsValidPAN(pan string) bool {
+	return panPattern.MatchString(pan)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// ImpersonationAuditLog records a request made with an impersonation access token.
+type ImpersonationAuditLog struct {
+	gorm.Model
+	ImpersonatorID uint   `json:"impersonator_id" gorm:"not null;index"`
+	UserID         uint   `json:"user_id" gorm:"not null;index"`
+	TokenID        string `json:"token_id" gorm:"not null;index"`
+	Method         string `json:"method" gorm:"not null"`
+	Path           string `json:"path" gorm:"not null"`
+	Status         int    `json:"status"`
+	IPAddress      string `json:"ip_address"`
+	UserAgent      string `json:"user_agent"`
+}
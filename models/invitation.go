@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation lets a user created by an admin set their password and activate
+// the account, or lets a user with an account join an organization.
+type Invitation struct {
+	gorm.Model
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	InvitedByID uint       `json:"invited_by_id" gorm:"not null"`
+	Email       string     `json:"email" gorm:"not null"`
+	TokenHash   string     `json:"-" gorm:"not null;unique"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	SentAt      time.Time  `json:"sent_at" gorm:"not null"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+
+	OrganizationID *uint  `json:"organization_id" gorm:"index"`
+	Role           string `json:"role"`
+	Owner          bool   `json:"owner"`
+	BranchIDs      []uint `json:"branch_ids" gorm:"serializer:json"`
+	// Membership marks invitations of users with an account, who only become a
+	// member with the role, the ownership and the branches above on acceptance.
+	// New users are members from the start. It is not exposed, so that an
+	// invitation does not tell whether the email has an account.
+	Membership bool `json:"-" gorm:"not null;default:false"`
+}
+
+func (i *Invitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil
+}
+
+// GrantsMembership reports whether accepting the invitation makes an existing
+// user a member, instead of activating a new account.
+func (i *Invitation) GrantsMembership() bool {
+	return i.Membership
+}
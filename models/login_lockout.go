@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	LockoutSubjectEmail = "email"
+	LockoutSubjectPhone = "phone"
+	// LockoutSubjectUser throttles the MFA codes of a user across challenges.
+	LockoutSubjectUser = "user"
+	LockoutSubjectIP   = "ip"
+)
+
+// LoginLockout is a temporary block of logins for an email, a phone, the MFA
+// codes of a user or a client IP.
+// It lives in Redis (or memory), not in the database.
+type LoginLockout struct {
+	Subject     string    `json:"subject"`
+	Value       string    `json:"value"`
+	LockedUntil time.Time `json:"locked_until"`
+}
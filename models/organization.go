@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// Organization is a business using the deployment. Users and their data belong
+// to an organization and are only visible within it.
+type Organization struct {
+	gorm.Model
+	Name string `json:"name" validate:"required,max=150" gorm:"not null"`
+}
+
+func (o *Organization) ValidateFields() error {
+	return validate.Struct(o)
+}
+
+// ScopeOrganization limits a query to the rows of the organization. A zero id
+// leaves the query unscoped, which is meant for platform administrators only.
+func ScopeOrganization(organizationID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if organizationID == 0 {
+			return db
+		}
+		return db.Where("organization_id = ?", organizationID)
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrganizationMember makes a user a member of an organization with a role of
+// that organization. Owners manage the members of the organization. Members
+// with branches are restricted to them, the others may work in every branch.
+// Removed members are deleted, so that they can be added again.
+type OrganizationMember struct {
+	ID             uint          `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	OrganizationID uint          `json:"organization_id" gorm:"not null;uniqueIndex:idx_organization_member"`
+	UserID         uint          `json:"user_id" gorm:"not null;uniqueIndex:idx_organization_member;index"`
+	Role           string        `json:"role" validate:"required,max=50" gorm:"not null"`
+	Owner          bool          `json:"owner" gorm:"not null;default:false"`
+	Organization   *Organization `json:"organization,omitempty"`
+	User           *User         `json:"user,omitempty"`
+	Branches       []*Branch     `json:"branches,omitempty" gorm:"many2many:organization_member_branches"`
+}
+
+func (m *OrganizationMember) ValidateFields() error {
+	return validate.Struct(m)
+}
+
+// ScopeOrganizationMembers limits a query on the rows of users, by the column,
+// to the members of the organization. A zero id leaves the query unscoped.
+func ScopeOrganizationMembers(column string, organizationID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if organizationID == 0 {
+			return db
+		}
+		members := db.Session(&gorm.Session{NewDB: true}).Model(&OrganizationMember{}).Select("user_id").Where("organization_id = ?", organizationID)
+		return db.Where(column+" IN (?)", members)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
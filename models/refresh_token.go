@@ -6,9 +6,52 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RefreshTokenRevokedRotated       = "rotated"
+	RefreshTokenRevokedLogout        = "logout"
+	RefreshTokenRevokedByUser        = "revoked"
+	RefreshTokenRevokedReuseDetected = "reuse_detected"
+)
+
+// RefreshToken is one refresh token of a login session. Every rotation revokes
+// the presented token and issues a new one in the same family, so a family is a
+// session on one device and its revoked tokens are kept as tombstones to detect
+// replays of already rotated tokens.
 type RefreshToken struct {
 	gorm.Model
-	UserID    uint      `gorm:"not null;index"`
-	TokenHash string    `gorm:"not null;unique"`
-	ExpiresAt time.Time `gorm:"not null"`
+	UserID        uint       `json:"-" gorm:"not null;index"`
+	FamilyID      string     `json:"session_id" gorm:"index"`
+	ParentID      *uint      `json:"-"`
+	TokenHash     string     `json:"-" gorm:"not null;unique"`
+	DeviceName    string     `json:"device_name"`
+	UserAgent     string     `json:"user_agent"`
+	IPAddress     string     `json:"ip_address"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt     *time.Time `json:"-" gorm:"index"`
+	RevokedReason string     `json:"-"`
+	Current       bool       `json:"current" gorm:"-"`
+
+	// OrganizationID and BranchID are the organization and branch the session
+	// acts in.
+	OrganizationID *uint `json:"organization_id"`
+	BranchID       *uint `json:"branch_id"`
+}
+
+func (rt *RefreshToken) OrganizationIDOrZero() uint {
+	if rt.OrganizationID == nil {
+		return 0
+	}
+	return *rt.OrganizationID
+}
+
+func (rt *RefreshToken) BranchIDOrZero() uint {
+	if rt.BranchID == nil {
+		return 0
+	}
+	return *rt.BranchID
+}
+
+func (rt *RefreshToken) IsRevoked() bool {
+	return rt.RevokedAt != nil
 }
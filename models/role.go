@@ -0,0 +1,134 @@
+package models
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PermissionAll                = "*"
+	PermissionUserRead           = "user:read"
+	PermissionUserCreate         = "user:create"
+	PermissionUserUpdate         = "user:update"
+	PermissionUserDelete         = "user:delete"
+	PermissionUserImpersonate    = "user:impersonate"
+	PermissionRoleRead           = "role:read"
+	PermissionRoleManage         = "role:manage"
+	PermissionAuditRead          = "audit:read"
+	PermissionOrganizationManage = "organization:manage"
+	PermissionBranchManage       = "branch:manage"
+	PermissionCustomerRead       = "customer:read"
+	PermissionCustomerCreate     = "customer:create"
+	PermissionCustomerUpdate     = "customer:update"
+	PermissionCustomerDelete     = "customer:delete"
+	PermissionInvoiceRead        = "invoice:read"
+	PermissionInvoiceCreate      = "invoice:create"
+	PermissionInvoiceUpdate      = "invoice:update"
+	PermissionInvoiceDelete      = "invoice:delete"
+)
+
+// Permissions lists every permission which can be granted to a role.
+var Permissions = []string{
+	PermissionUserRead,
+	PermissionUserCreate,
+	PermissionUserUpdate,
+	PermissionUserDelete,
+	PermissionUserImpersonate,
+	PermissionRoleRead,
+	PermissionRoleManage,
+	PermissionAuditRead,
+	PermissionOrganizationManage,
+	PermissionBranchManage,
+	PermissionCustomerRead,
+	PermissionCustomerCreate,
+	PermissionCustomerUpdate,
+	PermissionCustomerDelete,
+	PermissionInvoiceRead,
+	PermissionInvoiceCreate,
+	PermissionInvoiceUpdate,
+	PermissionInvoiceDelete,
+}
+
+// Role grants a set of permissions to the users holding it, referenced by name
+// from User.Role. Built-in roles are seeded by the migration and cannot be
+// changed through the API. Postgres treats NULL organizations as distinct, so
+// the names of shared roles have an index of their own.
+type Role struct {
+	gorm.Model
+	Name           string   `json:"name" validate:"required,max=50" gorm:"not null;uniqueIndex:idx_role_organization_name;uniqueIndex:idx_role_shared_name,where:organization_id IS NULL"`
+	Description    string   `json:"description"`
+	OrganizationID *uint    `json:"organization_id" gorm:"uniqueIndex:idx_role_organization_name"`
+	BuiltIn        bool     `json:"built_in" gorm:"not null;default:false"`
+	Permissions    []string `json:"permissions" gorm:"serializer:json;not null"`
+}
+
+func (r *Role) ValidateFields() error {
+	return validate.Struct(r)
+}
+
+func (r *Role) HasPermission(permission string) bool {
+	return HasPermission(r.Permissions, permission)
+}
+
+// HasPermission reports whether the permissions grant the given permission.
+func HasPermission(permissions []string, permission string) bool {
+	return slices.Contains(permissions, PermissionAll) || slices.Contains(permissions, permission)
+}
+
+// CanManageRole reports whether a user holding the actor role may create,
+// update or delete an account or role holding the target role. Only holders of
+// every permission may manage their peers; everyone else may only manage roles
+// with strictly fewer permissions than their own.
+func CanManageRole(actor, target *Role) bool {
+	if actor.HasPermission(PermissionAll) {
+		return true
+	}
+	if target.HasPermission(PermissionAll) {
+		return false
+	}
+
+	for _, permission := range target.Permissions {
+		if !actor.HasPermission(permission) {
+			return false
+		}
+	}
+	for _, permission := range actor.Permissions {
+		if !target.HasPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltInRoles are the roles every installation starts with.
+func BuiltInRoles() []Role {
+	return []Role{
+		{
+			Name:        RoleSuperAdmin,
+			Description: "Full access to everything",
+			BuiltIn:     true,
+			Permissions: []string{PermissionAll},
+		},
+		{
+			Name:        RoleAdmin,
+			Description: "Manages users and billing",
+			BuiltIn:     true,
+			Permissions: []string{
+				PermissionUserRead, PermissionUserCreate, PermissionUserUpdate, PermissionUserDelete,
+				PermissionRoleRead, PermissionRoleManage, PermissionAuditRead, PermissionBranchManage,
+				PermissionCustomerRead, PermissionCustomerCreate, PermissionCustomerUpdate, PermissionCustomerDelete,
+				PermissionInvoiceRead, PermissionInvoiceCreate, PermissionInvoiceUpdate, PermissionInvoiceDelete,
+			},
+		},
+		{
+			Name:        RoleUser,
+			Description: "Works with customers and invoices",
+			BuiltIn:     true,
+			Permissions: []string{
+				PermissionCustomerRead, PermissionCustomerCreate, PermissionCustomerUpdate,
+				PermissionInvoiceRead, PermissionInvoiceCreate, PermissionInvoiceUpdate,
+			},
+		},
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestCanManageRole(t *testing.T) {
+	all := &Role{Permissions: []string{PermissionAll}}
+	admin := &Role{Permissions: []string{PermissionUserRead, PermissionUserCreate, PermissionRoleManage}}
+	reader := &Role{Permissions: []string{PermissionUserRead}}
+	other := &Role{Permissions: []string{PermissionCustomerRead}}
+
+	tests := []struct {
+		name   string
+		actor  *Role
+		target *Role
+		want   bool
+	}{
+		{"every permission manages every role", all, admin, true},
+		{"every permission manages its peers", all, all, true},
+		{"superset manages subset", admin, reader, true},
+		{"subset cannot manage superset", reader, admin, false},
+		{"equal permissions cannot manage each other", admin, &Role{Permissions: admin.Permissions}, false},
+		{"disjoint permissions cannot be managed", admin, other, false},
+		{"nobody else manages every permission", admin, all, false},
+		{"empty role is managed by any role", reader, &Role{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanManageRole(tt.actor, tt.target); got != tt.want {
+				t.Errorf("CanManageRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
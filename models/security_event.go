@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	SecurityEventRefreshTokenReuse    = "refresh_token_reuse"
+	SecurityEventImpersonationStarted = "impersonation_started"
+	SecurityEventImpersonationStopped = "impersonation_stopped"
+)
+
+type SecurityEvent struct {
+	gorm.Model
+	UserID    uint   `json:"user_id" gorm:"index"`
+	Type      string `json:"type" gorm:"not null;index"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	Details   string `json:"details"`
+}
@@ -1,22 +1,82 @@
 package models
 
 import (
-	"os"
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 	"treeforms_billing/application_types"
+	"treeforms_billing/keyring"
 	"treeforms_billing/logger"
 
 	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
 )
 
+// Names of the built-in roles, see BuiltInRoles.
+const (
+	RoleSuperAdmin = "superadmin"
+	RoleAdmin      = "admin"
+	RoleUser       = "user"
+)
+
+const (
+	UserStatusActive   = "active"
+	UserStatusInactive = "inactive"
+)
+
 type User struct {
 	gorm.Model
 	Name   string `json:"name" validate:"required" gorm:"not null"`
 	Email  string `json:"email" validate:"required,email" gorm:"not null"`
 	Phone  string `json:"phone" validate:"required" gorm:"not null" `
-	Role   string `json:"role" validate:"required,oneof=superadmin admin" gorm:"not null"`
-	Status string `json:"role" validate:"required,oneof=active inactive" gorm:"not null"`
+	Role   string `json:"role" validate:"required,max=50" gorm:"not null"`
+	Status string `json:"status" validate:"required,oneof=active inactive" gorm:"not null"`
+
+	// OrganizationID is the organization the user logs in to, the one used last.
+	// The organizations of the user are their OrganizationMember rows.
+	OrganizationID *uint `json:"organization_id" gorm:"index"`
+	// BranchID is the branch the access tokens of the user act in, see InBranch.
+	BranchID uint `json:"-" gorm:"-"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+// IsActive reports whether the user may login and use their tokens.
+func (u *User) IsActive() bool {
+	return u.Status == UserStatusActive
+}
+
+// OrganizationIDOrZero returns the organization of the user, or zero for users
+// outside of any organization.
+func (u *User) OrganizationIDOrZero() uint {
+	if u.OrganizationID == nil {
+		return 0
+	}
+	return *u.OrganizationID
+}
+
+// InOrganization returns a copy of the user acting as the member, carrying the
+// organization and the role of the membership into the access token.
+func (u *User) InOrganization(member *OrganizationMember) *User {
+	scoped := *u
+	if member == nil {
+		scoped.OrganizationID = nil
+		return &scoped
+	}
+	scoped.OrganizationID = &member.OrganizationID
+	scoped.Role = member.Role
+	return &scoped
+}
+
+// InBranch returns a copy of the user acting in the branch.
+func (u *User) InBranch(branchID uint) *User {
+	scoped := *u
+	scoped.BranchID = branchID
+	return &scoped
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 func (u *User) ValidateFields() error {
@@ -24,25 +84,55 @@ func (u *User) ValidateFields() error {
 	return err
 }
 
-func (u *User) NewAccessToken() (string, error) {
-	token := application_types.AccessToken{
-		UserID: u.ID,
-		Name:   u.Name,
-		Role:   u.Role,
+const AccessTokenTTL = 5 * time.Minute
+
+// ImpersonationTokenMaxTTL caps the lifetime of impersonation access tokens,
+// which is also the longest lifetime of any access token.
+const ImpersonationTokenMaxTTL = time.Hour
+
+// NewAccessToken signs an access token for the user bound to the given session.
+// The claims are returned as well, so that the caller can track the token id.
+func (u *User) NewAccessToken(sessionID string) (string, *application_types.AccessToken, error) {
+	return u.newAccessToken(sessionID, AccessTokenTTL, nil)
+}
+
+// NewImpersonationAccessToken signs an access token for the user carrying the
+// impersonator in the act claim. It is not bound to a session and cannot be refreshed.
+func (u *User) NewImpersonationAccessToken(impersonator *User, ttl time.Duration) (string, *application_types.AccessToken, error) {
+	if ttl > ImpersonationTokenMaxTTL {
+		ttl = ImpersonationTokenMaxTTL
+	}
+	return u.newAccessToken("", ttl, &application_types.Actor{UserID: impersonator.ID, Name: impersonator.Name})
+}
+
+func (u *User) newAccessToken(sessionID string, ttl time.Duration, act *application_types.Actor) (string, *application_types.AccessToken, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		logger.HighlightedDanger("Unable to generate the token id. Message: " + err.Error())
+		return "", nil, err
+	}
+
+	token := &application_types.AccessToken{
+		UserID:         u.ID,
+		Name:           u.Name,
+		Role:           u.Role,
+		SessionID:      sessionID,
+		Act:            act,
+		OrganizationID: u.OrganizationIDOrZero(),
+		BranchID:       u.BranchID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)), // 5 minutes expiry
+			ID:        hex.EncodeToString(jti),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "Treeforms Billing Software",
 		},
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, token)
-	secret := []byte(os.Getenv("JWT_SIGNING_SECRET"))
-	tokenString, err := jwtToken.SignedString(secret)
+	tokenString, err := keyring.Get().Sign(token)
 	if err != nil {
 		logger.HighlightedDanger("Unable to sign the token. Message: " + err.Error())
-		return "", err
+		return "", nil, err
 	}
 
-	return tokenString, nil
+	return tokenString, token, nil
 }
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountAPIKeyRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	apiKeyRoutes := r.Group("/me/api-keys", authorizationMiddleware.RequireUserSession, authorizationMiddleware.BlockImpersonation)
+	apiKeyController := controller.NewAPIKeyController()
+
+	apiKeyRoutes.POST("", apiKeyController.Create)
+	apiKeyRoutes.GET("", apiKeyController.Find)
+	apiKeyRoutes.DELETE("/:id", apiKeyController.DeleteByID)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountAuditEventRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	auditEventRoutes := r.Group("/audit-events", authorizationMiddleware.RequireUserSession,
+		authorizationMiddleware.RequirePermission(models.PermissionAuditRead), authorizationMiddleware.RequireOrganization)
+	auditEventController := controller.NewAuditEventController()
+
+	auditEventRoutes.GET("", auditEventController.Find)
+}
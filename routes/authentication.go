@@ -12,5 +12,22 @@ func mountAuthenticationRoutes(r *gin.RouterGroup) {
 	ctrl := controller.NewAuthenticationController()
 	authenticationRoutes.POST("/signup", ctrl.Signup)
 	authenticationRoutes.POST("/login/email", ctrl.EmailLogin)
+	authenticationRoutes.POST("/login/phone/otp", ctrl.RequestPhoneOTP)
+	authenticationRoutes.POST("/login/phone", ctrl.PhoneLogin)
+	authenticationRoutes.POST("/login/mfa", ctrl.VerifyMFALogin)
 	authenticationRoutes.POST("/refresh-token", ctrl.RotateRefreshTokenWithNewAccessToken)
+	authenticationRoutes.POST("/logout", ctrl.Logout)
+	// Cookie mode logout; the refresh cookie is only sent to this path.
+	authenticationRoutes.DELETE("/refresh-token", ctrl.Logout)
+	authenticationRoutes.POST("/password/forgot", ctrl.ForgotPassword)
+	authenticationRoutes.POST("/password/reset", ctrl.ResetPassword)
+	authenticationRoutes.POST("/email/verify", ctrl.VerifyEmail)
+	authenticationRoutes.POST("/email/resend", ctrl.ResendVerificationEmail)
+
+	invitationCtrl := controller.NewInvitationController()
+	authenticationRoutes.POST("/invitations/accept", invitationCtrl.Accept)
+
+	ssoCtrl := controller.NewSSOController()
+	authenticationRoutes.GET("/oidc/:provider/login", ssoCtrl.Login)
+	authenticationRoutes.GET("/oidc/:provider/callback", ssoCtrl.Callback)
 }
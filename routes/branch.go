@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountBranchRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	branchRoutes := r.Group("/branches", authorizationMiddleware.RequireUserSession,
+		authorizationMiddleware.RequirePermission(models.PermissionBranchManage), authorizationMiddleware.RequireOrganization)
+	branchController := controller.NewBranchController()
+
+	branchRoutes.POST("", authorizationMiddleware.BlockImpersonation, branchController.Create)
+	branchRoutes.GET("", branchController.Find)
+	branchRoutes.GET("/:id", branchController.FindByID)
+	branchRoutes.PATCH("/:id", authorizationMiddleware.BlockImpersonation, branchController.UpdateByID)
+	branchRoutes.DELETE("/:id", authorizationMiddleware.BlockImpersonation, branchController.DeleteByID)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountCustomerRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	customerRoutes := r.Group("/customers", authorizationMiddleware.RequireOrganization)
+	customerController := controller.NewCustomerController()
+
+	read := authorizationMiddleware.RequireScope(models.ScopeCustomersRead)
+	write := authorizationMiddleware.RequireScope(models.ScopeCustomersWrite)
+	permission := authorizationMiddleware.RequirePermission
+
+	customerRoutes.POST("", permission(models.PermissionCustomerCreate), write, customerController.Create)
+	customerRoutes.GET("", permission(models.PermissionCustomerRead), read, customerController.Find)
+	customerRoutes.GET("/:id", permission(models.PermissionCustomerRead), read, customerController.FindByID)
+	customerRoutes.PATCH("/:id", permission(models.PermissionCustomerUpdate), write, customerController.UpdateByID)
+	customerRoutes.DELETE("/:id", permission(models.PermissionCustomerDelete), write, customerController.DeleteByID)
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountImpersonationRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	impersonationController := controller.NewImpersonationController()
+
+	r.DELETE("/impersonation", authorizationMiddleware.RequireUserSession, impersonationController.Stop)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountInvitationRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	invitationRoutes := r.Group("/invitations", authorizationMiddleware.RequireOrganization)
+	invitationController := controller.NewInvitationController()
+
+	read := authorizationMiddleware.RequireScope(models.ScopeUsersRead)
+	write := authorizationMiddleware.RequireScope(models.ScopeUsersWrite)
+
+	permission := authorizationMiddleware.RequirePermission
+
+	invitationRoutes.GET("", permission(models.PermissionUserRead), read, invitationController.Find)
+	invitationRoutes.POST("/:id/resend", permission(models.PermissionUserCreate), write, invitationController.Resend)
+	invitationRoutes.DELETE("/:id", permission(models.PermissionUserCreate), write, invitationController.Revoke)
+}
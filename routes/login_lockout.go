@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountLoginLockoutRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	// Lockouts of emails and IPs span every organization, so only holders of
+	// every permission may see and clear them.
+	lockoutRoutes := r.Group("/login-lockouts", authorizationMiddleware.RequireUserSession,
+		authorizationMiddleware.RequirePermission(models.PermissionAll))
+	lockoutController := controller.NewLoginLockoutController()
+
+	lockoutRoutes.GET("", lockoutController.Find)
+	lockoutRoutes.DELETE("", lockoutController.Clear)
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"treeforms_billing/controller"
 	"treeforms_billing/middlewares"
 
 	"github.com/gin-gonic/gin"
@@ -8,9 +9,25 @@ import (
 
 func MountHTTPRoutes(r *gin.Engine) {
 	authenticationMiddleware := middlewares.NewAuthenticationMiddleware()
+	r.Use(middlewares.NewRequestIDMiddleware().AssignRequestID)
+	r.GET("/.well-known/jwks.json", controller.NewJWKSController().GetJWKS)
+
 	api := r.Group("/api/v1")
 	apiProtected := r.Group("/api/v1", authenticationMiddleware.ValidateAccessToken)
 
 	mountUserRoutes(apiProtected)
+	mountMeRoutes(apiProtected)
+	mountInvitationRoutes(apiProtected)
+	mountImpersonationRoutes(apiProtected)
+	mountRoleRoutes(apiProtected)
+	mountMFARoutes(apiProtected)
+	mountSessionRoutes(apiProtected)
+	mountLoginLockoutRoutes(apiProtected)
+	mountAPIKeyRoutes(apiProtected)
+	mountAuditEventRoutes(apiProtected)
+	mountOrganizationRoutes(apiProtected)
+	mountOrganizationMemberRoutes(apiProtected)
+	mountBranchRoutes(apiProtected)
+	mountCustomerRoutes(apiProtected)
 	mountAuthenticationRoutes(api)
 }
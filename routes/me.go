@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountMeRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	meRoutes := r.Group("/me", authorizationMiddleware.RequireUserSession)
+	meController := controller.NewMeController()
+
+	meRoutes.GET("", meController.Find)
+	meRoutes.PATCH("", authorizationMiddleware.BlockImpersonation, meController.Update)
+	meRoutes.POST("/password", authorizationMiddleware.BlockImpersonation, meController.ChangePassword)
+	meRoutes.GET("/organizations", meController.FindOrganizations)
+	meRoutes.POST("/organizations/:id/switch", authorizationMiddleware.BlockImpersonation, meController.SwitchOrganization)
+	meRoutes.GET("/branches", meController.FindBranches)
+	meRoutes.POST("/branches/:id/switch", authorizationMiddleware.BlockImpersonation, meController.SwitchBranch)
+}
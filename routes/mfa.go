@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountMFARoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	mfaRoutes := r.Group("/me/mfa", authorizationMiddleware.RequireUserSession, authorizationMiddleware.BlockImpersonation)
+	mfaController := controller.NewMFAController()
+
+	mfaRoutes.POST("/enroll", mfaController.Enroll)
+	mfaRoutes.POST("/confirm", mfaController.Confirm)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountOrganizationRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	organizationRoutes := r.Group("/organizations", authorizationMiddleware.RequireUserSession,
+		authorizationMiddleware.RequirePermission(models.PermissionOrganizationManage))
+	organizationController := controller.NewOrganizationController()
+
+	organizationRoutes.POST("", organizationController.Create)
+	organizationRoutes.GET("", organizationController.Find)
+	organizationRoutes.GET("/:id", organizationController.FindByID)
+	organizationRoutes.PATCH("/:id", organizationController.UpdateByID)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountOrganizationMemberRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	memberRoutes := r.Group("/organization/members", authorizationMiddleware.RequireUserSession,
+		authorizationMiddleware.RequireOrganizationOwner)
+	memberController := controller.NewOrganizationMemberController()
+
+	memberRoutes.GET("", memberController.Find)
+	memberRoutes.POST("", authorizationMiddleware.BlockImpersonation, memberController.Add)
+	memberRoutes.PATCH("/:userID", authorizationMiddleware.BlockImpersonation, memberController.UpdateByUserID)
+	memberRoutes.DELETE("/:userID", authorizationMiddleware.BlockImpersonation, memberController.RemoveByUserID)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountRoleRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	roleRoutes := r.Group("/roles", authorizationMiddleware.RequireUserSession, authorizationMiddleware.RequireOrganization)
+	roleController := controller.NewRoleController()
+
+	read := authorizationMiddleware.RequirePermission(models.PermissionRoleRead)
+	manage := authorizationMiddleware.RequirePermission(models.PermissionRoleManage)
+
+	roleRoutes.GET("", read, roleController.Find)
+	roleRoutes.GET("/permissions", read, roleController.FindPermissions)
+	roleRoutes.GET("/:id", read, roleController.FindByID)
+	roleRoutes.POST("", manage, authorizationMiddleware.BlockImpersonation, roleController.Create)
+	roleRoutes.PATCH("/:id", manage, authorizationMiddleware.BlockImpersonation, roleController.UpdateByID)
+	roleRoutes.DELETE("/:id", manage, authorizationMiddleware.BlockImpersonation, roleController.DeleteByID)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func mountSessionRoutes(r *gin.RouterGroup) {
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	sessionRoutes := r.Group("/me/sessions", authorizationMiddleware.RequireUserSession)
+	sessionController := controller.NewSessionController()
+
+	sessionRoutes.GET("", sessionController.Find)
+	sessionRoutes.DELETE("", authorizationMiddleware.BlockImpersonation, sessionController.DeleteAll)
+	sessionRoutes.DELETE("/:id", authorizationMiddleware.BlockImpersonation, sessionController.DeleteByID)
+}
@@ -2,17 +2,30 @@ package routes
 
 import (
 	"treeforms_billing/controller"
+	"treeforms_billing/middlewares"
+	"treeforms_billing/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func mountUserRoutes(r *gin.RouterGroup) {
-	userRoutes := r.Group("/user")
+	authorizationMiddleware := middlewares.NewAuthorizationMiddleware()
+	userRoutes := r.Group("/user", authorizationMiddleware.RequireOrganization)
 	userController := controller.NewUserController()
+	mfaController := controller.NewMFAController()
+	impersonationController := controller.NewImpersonationController()
 
-	userRoutes.POST("", userController.Create)
-	userRoutes.GET("", userController.Find)
-	userRoutes.GET("/:id", userController.FindByID)
-	userRoutes.PATCH("/:id", userController.UpdateByID)
-	userRoutes.DELETE("/:id", userController.DeleteByID)
+	read := authorizationMiddleware.RequireScope(models.ScopeUsersRead)
+	write := authorizationMiddleware.RequireScope(models.ScopeUsersWrite)
+	permission := authorizationMiddleware.RequirePermission
+
+	userRoutes.POST("", permission(models.PermissionUserCreate), write, authorizationMiddleware.AuthorizeUserManagement, userController.Create)
+	userRoutes.GET("", permission(models.PermissionUserRead), read, userController.Find)
+	userRoutes.GET("/:id", permission(models.PermissionUserRead), read, userController.FindByID)
+	userRoutes.PATCH("/:id", permission(models.PermissionUserUpdate), write, authorizationMiddleware.AuthorizeUserManagement, userController.UpdateByID)
+	userRoutes.DELETE("/:id", permission(models.PermissionUserDelete), write, authorizationMiddleware.AuthorizeUserManagement, userController.DeleteByID)
+	userRoutes.DELETE("/:id/mfa", permission(models.PermissionUserUpdate), authorizationMiddleware.RequireUserSession, authorizationMiddleware.BlockImpersonation,
+		authorizationMiddleware.AuthorizeUserManagement, mfaController.ResetByUserID)
+	userRoutes.POST("/:id/impersonate", permission(models.PermissionUserImpersonate), authorizationMiddleware.RequireUserSession,
+		authorizationMiddleware.BlockImpersonation, impersonationController.Start)
 }
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+const apiKeyTokenPrefix = "tfb_"
+
+type apiKeyService struct {
+	userSvc UserService
+	db      *gorm.DB
+}
+
+type APIKeyService interface {
+	Create(userID, organizationID, branchID uint, apiKeyDTO *dtos.APIKeyDTO) (key string, apiKey *models.APIKey, appErr *application_types.ApplicationError)
+	FindByUserID(userID uint) ([]*models.APIKey, *application_types.ApplicationError)
+	RevokeByID(userID uint, id uint) *application_types.ApplicationError
+	Authenticate(key string) (*models.APIKey, *models.User, *application_types.ApplicationError)
+}
+
+func NewAPIKeyService() APIKeyService {
+	return &apiKeyService{
+		userSvc: NewUserService(),
+		db:      db.Get(),
+	}
+}
+
+// Create issues a new API key. The plain key is returned only here; afterwards
+// the key can be recognised by its prefix only.
+func (svc *apiKeyService) Create(userID, organizationID, branchID uint, apiKeyDTO *dtos.APIKeyDTO) (key string, apiKey *models.APIKey, appErr *application_types.ApplicationError) {
+	logger.Info("Creating a new api key for the user id " + strconv.FormatUint(uint64(userID), 10))
+	apiKey = &models.APIKey{UserID: userID, Name: apiKeyDTO.Name, Scopes: apiKeyDTO.Scopes, ExpiresAt: apiKeyDTO.ExpiresAt,
+		OrganizationID: optionalID(organizationID), BranchID: optionalID(branchID)}
+
+	if err := apiKey.ValidateFields(); err != nil {
+		appErr = application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request",
+			fmt.Errorf("Validation failed for creating the api key. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return "", nil, appErr
+	}
+
+	for _, scope := range apiKey.Scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			logger.Warning("Unknown api key scope " + scope)
+			return "", nil, application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request", fmt.Errorf("Unknown scope '%s'", scope))
+		}
+	}
+
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now()) {
+		logger.Warning("Api key expiry is in the past")
+		return "", nil, application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request", fmt.Errorf("Expiry must be in the future"))
+	}
+
+	token, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate api key. Message: " + err.Error())
+		return "", nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "API key creation failed", err)
+	}
+
+	key = apiKeyTokenPrefix + token
+	apiKey.Prefix = key[:len(apiKeyTokenPrefix)+8]
+	apiKey.KeyHash = auth.HashOpaqueToken(key)
+
+	if err := svc.db.Create(apiKey).Error; err != nil {
+		logger.Danger("API key creation failed. Gorm Message: " + err.Error())
+		return "", nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "API key creation failed", err)
+	}
+
+	logger.Success("API key created succesfully.")
+	return key, apiKey, nil
+}
+
+func (svc *apiKeyService) FindByUserID(userID uint) ([]*models.APIKey, *application_types.ApplicationError) {
+	logger.Info("Finding api keys of the user id " + strconv.FormatUint(uint64(userID), 10))
+	var apiKeys []*models.APIKey
+	if err := svc.db.Where("user_id = ?", userID).Order("id DESC").Find(&apiKeys).Error; err != nil {
+		logger.Danger("Unable to find api keys. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "API key find failed!", err)
+	}
+
+	logger.Success("API keys found successfully")
+	return apiKeys, nil
+}
+
+func (svc *apiKeyService) RevokeByID(userID uint, id uint) *application_types.ApplicationError {
+	logger.Info("Revoking the api key id " + strconv.FormatUint(uint64(id), 10))
+	res := svc.db.Model(&models.APIKey{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).Update("revoked_at", time.Now())
+	if res.Error != nil {
+		logger.Danger("Unable to revoke api key. Message: " + res.Error.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "API key revoke failed", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		logger.Warning("No active api key found for the id " + strconv.FormatUint(uint64(id), 10))
+		return application_types.NewApplicationError(false, http.StatusNotFound, "No api key found for the given id", fmt.Errorf("API key not found"))
+	}
+
+	logger.Success("Revoked the api key id " + strconv.FormatUint(uint64(id), 10))
+	return nil
+}
+
+func (svc *apiKeyService) Authenticate(key string) (*models.APIKey, *models.User, *application_types.ApplicationError) {
+	var apiKey models.APIKey
+	if err := svc.db.Where("key_hash = ?", auth.HashOpaqueToken(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("Invalid api key")
+			return nil, nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid API key", fmt.Errorf("Invalid API key"))
+		}
+		logger.Danger("Unable to find api key. Message: " + err.Error())
+		return nil, nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "API key authentication failed", err)
+	}
+
+	if !apiKey.IsActive() {
+		logger.Warning("Revoked or expired api key " + apiKey.Prefix)
+		return nil, nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid API key", fmt.Errorf("API key is revoked or expired"))
+	}
+
+	user, appErr := svc.userSvc.FindByID(apiKey.UserID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	if appErr := ensureActive(user); appErr != nil {
+		logger.Warning("Api key " + apiKey.Prefix + " belongs to an inactive user")
+		return nil, nil, appErr
+	}
+
+	if err := svc.db.Model(&apiKey).UpdateColumn("last_used_at", time.Now()).Error; err != nil {
+		logger.Warning("Unable to update api key last used time. Message: " + err.Error())
+	}
+
+	return &apiKey, user, nil
+}
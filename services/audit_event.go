@@ -0,0 +1,120 @@
+package services
+
+import (
+	"net/http"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+type auditEventService struct {
+	db *gorm.DB
+}
+
+type AuditEventService interface {
+	Record(event *models.AuditEvent)
+	Find(filter models.AuditEventFilter) (events []*models.AuditEvent, total int64, appErr *application_types.ApplicationError)
+}
+
+func NewAuditEventService() AuditEventService {
+	return &auditEventService{
+		db: db.Get(),
+	}
+}
+
+// newAuditEvent returns an event of the action made by the actor. Events of
+// anonymous requests, like failed logins, have a zero actor user id.
+func newAuditEvent(action, outcome string, actor dtos.Actor) *models.AuditEvent {
+	return &models.AuditEvent{
+		Action:         action,
+		Outcome:        outcome,
+		ActorID:        optionalID(actor.UserID),
+		ImpersonatorID: optionalID(actor.ImpersonatorID),
+		OrganizationID: optionalID(actor.OrganizationID),
+		IPAddress:      actor.Client.IPAddress,
+		UserAgent:      actor.Client.UserAgent,
+		RequestID:      actor.Client.RequestID,
+	}
+}
+
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// Record stores the event. The action it records has already happened, so a
+// failure is logged but not returned. Events about a user without an actor
+// organization, like logins, belong to the organization of the user.
+func (svc *auditEventService) Record(event *models.AuditEvent) {
+	if event.OrganizationID == nil && event.TargetType == models.AuditTargetUser && event.TargetID != nil {
+		var user models.User
+		if err := svc.db.Unscoped().Select("organization_id").First(&user, *event.TargetID).Error; err != nil {
+			logger.Warning("Unable to find the organization of the audited user. Gorm Message: " + err.Error())
+		}
+		event.OrganizationID = user.OrganizationID
+	}
+
+	if err := svc.db.Create(event).Error; err != nil {
+		logger.HighlightedDanger("Unable to record the audit event '" + event.Action + "'. Gorm Message: " + err.Error())
+	}
+}
+
+// Find returns a page of the matching events, newest first, and the number of
+// all matching events.
+func (svc *auditEventService) Find(filter models.AuditEventFilter) ([]*models.AuditEvent, int64, *application_types.ApplicationError) {
+	logger.Info("Finding audit events")
+	filter.Normalize()
+
+	query := svc.db.Model(&models.AuditEvent{}).Scopes(models.ScopeOrganization(filter.OrganizationID))
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.IPAddress != "" {
+		query = query.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Danger("Unable to count audit events. Gorm Message: " + err.Error())
+		return nil, 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Audit event find failed!", err)
+	}
+
+	var events []*models.AuditEvent
+	err := query.Order("created_at DESC, id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).
+		Find(&events).Error
+	if err != nil {
+		logger.Danger("Unable to find audit events. Gorm Message: " + err.Error())
+		return nil, 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Audit event find failed!", err)
+	}
+
+	logger.Success("Audit events found successfully")
+	return events, total, nil
+}
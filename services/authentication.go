@@ -1,11 +1,11 @@
 package services
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"treeforms_billing/application_types"
 	"treeforms_billing/auth"
@@ -14,69 +14,265 @@ import (
 	"treeforms_billing/logger"
 	"treeforms_billing/models"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
 type authenticationService struct {
-	userSvc UserService
-	passSvc PasswordService
-	db      *gorm.DB
+	userSvc              UserService
+	passSvc              PasswordService
+	mfaSvc               MFAService
+	sessSvc              SessionService
+	securityEventSvc     SecurityEventService
+	throttleSvc          LoginThrottleService
+	revocationSvc        TokenRevocationService
+	emailVerificationSvc EmailVerificationService
+	phoneOTPSvc          PhoneOTPService
+	oidcSvc              OIDCService
+	auditSvc             AuditEventService
+	memberSvc            OrganizationMemberService
+	db                   *gorm.DB
 }
 
 type AuthenticationService interface {
-	EmailLogin(emailID string, passwordStr string) (access_token, refresh_token string, sub uint, appErr *application_types.ApplicationError)
+	EmailLogin(emailID string, passwordStr string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError)
+	PhoneLogin(phone, otp string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError)
+	SSOLogin(provider, code, state string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError)
+	VerifyMFALogin(mfaToken, code string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, appErr *application_types.ApplicationError)
 	Signup(signup dtos.SignupDTO) *application_types.ApplicationError
-	RotateRefreshTokenWithNewAccessToken(refreshToken string, userID uint) (access_token, refresh_token string, appErr *application_types.ApplicationError)
+	ChangePassword(userID uint, changePassword dtos.ChangePasswordDTO, client dtos.ClientInfo) (access_token, refresh_token string, appErr *application_types.ApplicationError)
+	RotateRefreshTokenWithNewAccessToken(refreshToken string, userID uint, client dtos.ClientInfo) (access_token, refresh_token string, appErr *application_types.ApplicationError)
+	Logout(refreshToken string) *application_types.ApplicationError
+	SwitchOrganization(claims *application_types.AccessToken, organizationID uint, client dtos.ClientInfo) (access_token string, appErr *application_types.ApplicationError)
+	SwitchBranch(claims *application_types.AccessToken, branchID uint, client dtos.ClientInfo) (access_token string, appErr *application_types.ApplicationError)
 }
 
 func NewAuthenticationSevice() AuthenticationService {
 	return &authenticationService{
-		userSvc: NewUserService(),
-		passSvc: NewPasswordService(),
-		db:      db.Get(),
+		userSvc:              NewUserService(),
+		passSvc:              NewPasswordService(),
+		mfaSvc:               NewMFAService(),
+		sessSvc:              NewSessionService(),
+		securityEventSvc:     NewSecurityEventService(),
+		throttleSvc:          NewLoginThrottleService(),
+		revocationSvc:        NewTokenRevocationService(),
+		emailVerificationSvc: NewEmailVerificationService(),
+		phoneOTPSvc:          NewPhoneOTPService(),
+		oidcSvc:              NewOIDCService(),
+		auditSvc:             NewAuditEventService(),
+		memberSvc:            NewOrganizationMemberService(),
+		db:                   db.Get(),
 	}
 }
 
-func (svc *authenticationService) EmailLogin(emailID string, passwordStr string) (access_token, refresh_token string, sub uint, appErr *application_types.ApplicationError) {
+// EmailLogin verifies the credentials and issues the tokens. When the user has
+// MFA enabled no tokens are issued; an mfa_token is returned instead, which has
+// to be completed with VerifyMFALogin.
+func (svc *authenticationService) EmailLogin(emailID string, passwordStr string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError) {
 	logger.Info("Email login Service Started")
+	var userID uint
+	defer func() { svc.auditLogin("Email", emailID, userID, client, mfa_token != "", appErr) }()
+
+	if appErr = svc.throttleSvc.Check(models.LockoutSubjectEmail, emailID, client.IPAddress); appErr != nil {
+		logger.Danger("Stopping Email login service.")
+		return
+	}
+
 	user, appErr := svc.userSvc.FindByEmail(emailID)
 	if appErr != nil {
+		if errors.Is(appErr.GetError(), gorm.ErrRecordNotFound) {
+			// Answer as for a wrong password, not to reveal whether the email is registered.
+			svc.throttleSvc.RecordFailure(models.LockoutSubjectEmail, emailID, client.IPAddress)
+			appErr = application_types.NewApplicationError(false, http.StatusUnauthorized, "Login using email failed", fmt.Errorf("Invalid Credentials"))
+		}
 		logger.Danger("Stopping Email login service.")
 		return
 	}
+	userID = user.ID
 
 	password, err := auth.GetPasswordByUserID(user.ID)
 	if err != nil {
 		logger.HighlightedDanger("Stopping Email login service.")
-		return "", "", 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Login using email failed", err)
+		return "", "", 0, "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Login using email failed", err)
 	}
 
 	if password == nil {
-		logger.HighlightedDanger("Password not created for the user")
-		return "", "", 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Login using email failed", fmt.Errorf("Password not created for the user"))
+		// Accounts provisioned through SSO have no password until one is reset.
+		logger.Warning("Stopping Email login service. Message: Password not created for the user")
+		svc.throttleSvc.RecordFailure(models.LockoutSubjectEmail, emailID, client.IPAddress)
+		return "", "", 0, "", application_types.NewApplicationError(false, http.StatusUnauthorized, "Login using email failed", fmt.Errorf("Invalid Credentials"))
 	}
 
 	if !password.VerifyPassword(passwordStr) {
 		logger.Info("Stopping Email login service. Message: Invalid password")
-		return "", "", 0, application_types.NewApplicationError(false, http.StatusUnauthorized, "Login using email failed", fmt.Errorf("Invalid Credentials"))
+		svc.throttleSvc.RecordFailure(models.LockoutSubjectEmail, emailID, client.IPAddress)
+		return "", "", 0, "", application_types.NewApplicationError(false, http.StatusUnauthorized, "Login using email failed", fmt.Errorf("Invalid Credentials"))
 	}
 
-	logger.Success("Email login is success")
-	tokenStr, err := user.NewAccessToken()
-	if err != nil {
-		logger.HighlightedDanger("Error occured while signing access token")
-		return "", "", 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Token Signing Failed", err)
+	// The plain password is only known here, so outdated hashes are upgraded on login.
+	if password.NeedsRehash() {
+		if err := password.Rehash(passwordStr); err != nil {
+			logger.Warning("Unable to rehash the password. Message: " + err.Error())
+		} else {
+			logger.Info("Password rehashed with the current hasher")
+		}
+	}
+
+	// Failures are only cleared once the login is complete, including MFA.
+	access_token, refresh_token, sub, mfa_token, appErr = svc.completeLogin(user, client, "Email login")
+	if appErr == nil && mfa_token == "" {
+		svc.throttleSvc.RecordSuccess(models.LockoutSubjectEmail, emailID)
+	}
+	return
+}
+
+// PhoneLogin logs in with an OTP sent through RequestOTP of the PhoneOTPService.
+// Like EmailLogin it returns an mfa_token instead of tokens when MFA is enabled.
+func (svc *authenticationService) PhoneLogin(phone, otp string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError) {
+	logger.Info("Phone login Service Started")
+	var userID uint
+	defer func() { svc.auditLogin("Phone", phone, userID, client, mfa_token != "", appErr) }()
+
+	if appErr = svc.throttleSvc.Check(models.LockoutSubjectPhone, phone, client.IPAddress); appErr != nil {
+		logger.Danger("Stopping Phone login service.")
+		return
+	}
+
+	userID, appErr = svc.phoneOTPSvc.VerifyOTP(phone, otp)
+	if appErr != nil {
+		if appErr.GetHTTPStatusCode() != http.StatusInternalServerError {
+			svc.throttleSvc.RecordFailure(models.LockoutSubjectPhone, phone, client.IPAddress)
+		}
+		logger.Danger("Stopping Phone login service.")
+		return
+	}
+
+	user, appErr := svc.userSvc.FindByID(userID)
+	if appErr != nil {
+		logger.Danger("Stopping Phone login service.")
+		return
+	}
+
+	access_token, refresh_token, sub, mfa_token, appErr = svc.completeLogin(user, client, "Phone login")
+	if appErr == nil && mfa_token == "" {
+		svc.throttleSvc.RecordSuccess(models.LockoutSubjectPhone, phone)
+	}
+	return
+}
+
+// SSOLogin completes an OpenID Connect login started with the authorization URL
+// of the OIDCService. Like EmailLogin it returns an mfa_token instead of tokens
+// when MFA is enabled.
+func (svc *authenticationService) SSOLogin(provider, code, state string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError) {
+	logger.Info("SSO login Service Started")
+	var userID uint
+	defer func() { svc.auditLogin("SSO", provider, userID, client, mfa_token != "", appErr) }()
+
+	user, appErr := svc.oidcSvc.ResolveUser(provider, code, state)
+	if appErr != nil {
+		logger.Danger("Stopping SSO login service.")
+		return
+	}
+	userID = user.ID
+
+	return svc.completeLogin(user, client, "SSO login")
+}
+
+// completeLogin issues the tokens for an authenticated user, or an mfa_token
+// when the user has MFA enabled. Every login method requires a verified email,
+// so that no account is used with an address nobody has confirmed.
+func (svc *authenticationService) completeLogin(user *models.User, client dtos.ClientInfo, serviceName string) (access_token, refresh_token string, sub uint, mfa_token string, appErr *application_types.ApplicationError) {
+	if appErr = ensureActive(user); appErr != nil {
+		logger.Warning("Stopping " + serviceName + " service. Message: User is inactive")
+		return
+	}
+
+	if !user.IsEmailVerified() {
+		logger.Warning("Stopping " + serviceName + " service. Message: Email not verified")
+		return "", "", 0, "", application_types.NewApplicationError(false, http.StatusForbidden, serviceName+" failed",
+			fmt.Errorf("Please verify your email address before logging in"))
+	}
+
+	mfaEnabled, appErr := svc.mfaSvc.IsEnabled(user.ID)
+	if appErr != nil {
+		logger.Danger("Stopping " + serviceName + " service.")
+		return
+	}
+
+	if mfaEnabled {
+		mfa_token, appErr = svc.mfaSvc.NewChallenge(user.ID)
+		if appErr != nil {
+			logger.Danger("Stopping " + serviceName + " service.")
+			return "", "", 0, "", appErr
+		}
+
+		logger.Success(serviceName + " verified. Waiting for MFA verification.")
+		return "", "", user.ID, mfa_token, nil
+	}
+
+	access_token, refresh_token, appErr = svc.issueTokens(user, client)
+	if appErr != nil {
+		return "", "", 0, "", appErr
+	}
+
+	logger.Success(serviceName + " success")
+	return access_token, refresh_token, user.ID, "", nil
+}
+
+func (svc *authenticationService) VerifyMFALogin(mfaToken, code string, client dtos.ClientInfo) (access_token, refresh_token string, sub uint, appErr *application_types.ApplicationError) {
+	logger.Info("Verify MFA login Service Started")
+	var userID uint
+	defer func() { svc.auditLogin("MFA", "", userID, client, false, appErr) }()
+
+	userID, appErr = svc.mfaSvc.VerifyChallenge(mfaToken, code, client.IPAddress)
+	if appErr != nil {
+		logger.Danger("Verify MFA login Service Stopped")
+		return
+	}
+
+	user, appErr := svc.userSvc.FindByID(userID)
+	if appErr != nil {
+		logger.Danger("Verify MFA login Service Stopped")
+		return
+	}
+
+	if appErr = ensureActive(user); appErr != nil {
+		logger.Warning("Verify MFA login Service Stopped. Message: User is inactive")
+		return
 	}
 
-	refresh_token, appErr = svc.NewRefreshToken(user.ID)
+	access_token, refresh_token, appErr = svc.issueTokens(user, client)
+	if appErr != nil {
+		return "", "", 0, appErr
+	}
+	// The login that started the challenge is complete now.
+	svc.throttleSvc.RecordSuccess(models.LockoutSubjectEmail, user.Email)
+	svc.throttleSvc.RecordSuccess(models.LockoutSubjectPhone, user.Phone)
+
+	logger.Success("Verify MFA login Service success")
+	return access_token, refresh_token, user.ID, nil
+}
+
+// issueTokens starts a session of the user in the organization they used last.
+func (svc *authenticationService) issueTokens(user *models.User, client dtos.ClientInfo) (access_token, refresh_token string, appErr *application_types.ApplicationError) {
+	user, appErr = svc.memberSvc.ResolveUser(user, 0, 0)
+	if appErr != nil {
+		return "", "", appErr
+	}
+
+	refresh_token, session, appErr := svc.NewRefreshToken(user, client)
 	if appErr != nil {
 		logger.HighlightedDanger("Error occured while generation refresh token")
-		return "", "", 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Refresh Token Generation Failed", err)
+		return "", "", appErr
+	}
+
+	access_token, appErr = svc.signAccessToken(user, session.FamilyID)
+	if appErr != nil {
+		return "", "", appErr
 	}
 
-	logger.Success("Email login success")
-	return tokenStr, refresh_token, user.ID, nil
+	return access_token, refresh_token, nil
 }
 
 func (svc *authenticationService) Signup(signup dtos.SignupDTO) *application_types.ApplicationError {
@@ -90,8 +286,8 @@ func (svc *authenticationService) Signup(signup dtos.SignupDTO) *application_typ
 		Name:   signup.Name,
 		Email:  signup.Email,
 		Phone:  signup.Phone,
-		Role:   "user",
-		Status: "active",
+		Role:   models.RoleUser,
+		Status: models.UserStatusActive,
 	}
 
 	u, appErr := svc.userSvc.FindByEmail(user.Email)
@@ -103,22 +299,44 @@ func (svc *authenticationService) Signup(signup dtos.SignupDTO) *application_typ
 		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Signup failed.", fmt.Errorf("Email already registered with another user."))
 	}
 
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(signup.ConfirmPassword), bcrypt.DefaultCost)
-	if err != nil {
-		logger.HighlightedDanger("User signup failed. Unable to hash the password. Message: " + err.Error())
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Signup Failed", fmt.Errorf("error occurred while hashing password: %w", err))
+	// Checked before the transaction so that policy errors are not hidden by it.
+	if err := auth.ValidatePassword(svc.db, 0, signup.Password); err != nil {
+		logger.Warning("User signup service stopped. Message: " + err.Error())
+		return newPasswordError(err, "password", "Signup Failed")
 	}
 
-	err = svc.db.Transaction(func(tx *gorm.DB) error {
+	organization := &models.Organization{Name: strings.TrimSpace(signup.OrganizationName)}
+	if organization.Name == "" {
+		organization.Name = signup.Name
+	}
+	if err := organization.ValidateFields(); err != nil {
+		logger.Warning("User signup service stopped. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Signup Failed",
+			fmt.Errorf("Invalid organization name")).AddFieldErrors("organization_name", "is required and must be at most 150 characters")
+	}
+
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		// Every signup starts a new organization.
+		if err := tx.Create(organization).Error; err != nil {
+			logger.HighlightedDanger("User signup failed. Unable to create organization in db. Gorm Message: " + err.Error())
+			return err
+		}
+		user.OrganizationID = &organization.ID
+
 		if err := tx.Create(user).Error; err != nil {
 			logger.HighlightedDanger("User signup failed. Unable to create user in db. Gorm Message: " + err.Error())
 			return err
 		}
 
-		passwordInsertQuery := `INSERT INTO passwords (hash, user_id) VALUES (?, ?);`
-		logger.Info("Executing query: " + passwordInsertQuery)
-		if err := tx.Exec(passwordInsertQuery, string(hashedPass), user.ID).Error; err != nil {
-			logger.HighlightedDanger("Password creation failed. Gorm Message: " + err.Error())
+		// The user owns the new organization and administers it.
+		member := &models.OrganizationMember{OrganizationID: organization.ID, UserID: user.ID, Role: models.RoleAdmin, Owner: true}
+		if err := addOrganizationMember(tx, member); err != nil {
+			logger.HighlightedDanger("User signup failed. Unable to add the user to the organization. Gorm Message: " + err.Error())
+			return err
+		}
+
+		if _, err := auth.NewPasswordWithTx(tx, user.ID, signup.Password); err != nil {
+			logger.HighlightedDanger("Password creation failed. Message: " + err.Error())
 			return err
 		}
 
@@ -126,86 +344,164 @@ func (svc *authenticationService) Signup(signup dtos.SignupDTO) *application_typ
 	})
 
 	if err != nil {
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Signup Failed", err)
+		return newPasswordError(err, "password", "Signup Failed")
+	}
+
+	// The account exists at this point; a failed email can be resent by the user.
+	if appErr := svc.emailVerificationSvc.SendVerification(user); appErr != nil {
+		logger.Warning("Unable to send the verification email after signup. Message: " + appErr.GetErrorMessage())
 	}
 
 	logger.Success("User Signup Service Success")
 	return nil
 }
 
-func (svc *authenticationService) NewAccessToken(userID uint) (string, *application_types.ApplicationError) {
+// ChangePassword changes the password after verifying the current one. Every
+// session of the user is revoked and the tokens of a new session are returned.
+func (svc *authenticationService) ChangePassword(userID uint, changePassword dtos.ChangePasswordDTO, client dtos.ClientInfo) (access_token, refresh_token string, appErr *application_types.ApplicationError) {
+	logger.Info("Change password with session rotation service started")
+	if changePassword.NewPassword != changePassword.ConfirmPassword {
+		logger.Danger("Confirm password and password are not identical.")
+		return "", "", application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Unable to change password",
+			fmt.Errorf("New password and Confirm passwords are not identical")).AddFieldErrors("confirm_password", "must match the new password")
+	}
+
+	user, appErr := svc.userSvc.FindByID(userID)
+	if appErr != nil {
+		logger.Danger("Change password with session rotation service stopped")
+		return "", "", appErr
+	}
+
+	if appErr = svc.passSvc.ChangePassword(userID, changePassword.CurrentPassword, changePassword.NewPassword); appErr != nil {
+		svc.recordAudit(models.AuditActionPasswordChanged, models.AuditOutcomeFailure, userID, client, appErr.GetErrorMessage())
+		logger.Danger("Change password with session rotation service stopped")
+		return "", "", appErr
+	}
+	svc.recordAudit(models.AuditActionPasswordChanged, models.AuditOutcomeSuccess, userID, client, "Password changed and every session revoked")
+
+	if appErr = svc.sessSvc.RevokeAll(userID); appErr != nil {
+		logger.Danger("Change password with session rotation service stopped")
+		return "", "", appErr
+	}
+
+	access_token, refresh_token, appErr = svc.issueTokens(user, client)
+	if appErr != nil {
+		return "", "", appErr
+	}
+
+	logger.Success("Change password with session rotation service success")
+	return access_token, refresh_token, nil
+}
+
+func (svc *authenticationService) NewAccessToken(userID uint, sessionID string) (string, *application_types.ApplicationError) {
 	logger.Info("Started New Access Token Service")
 	user, appErr := svc.userSvc.FindByID(userID)
 	if appErr != nil {
 		logger.Danger("New Access Token Service stopped")
 		return "", appErr
 	}
+	user, appErr = svc.memberSvc.ResolveUser(user, 0, 0)
+	if appErr != nil {
+		logger.Danger("New Access Token Service stopped")
+		return "", appErr
+	}
+
+	tokenStr, appErr := svc.signAccessToken(user, sessionID)
+	if appErr != nil {
+		logger.Danger("New Access Token Service stopped")
+		return "", appErr
+	}
 
-	tokenStr, err := user.NewAccessToken()
+	logger.Success("New Access Token Service Success")
+	return tokenStr, nil
+}
+
+// signAccessToken signs an access token and tracks its id so that it can be
+// revoked together with the session or the user.
+func (svc *authenticationService) signAccessToken(user *models.User, sessionID string) (string, *application_types.ApplicationError) {
+	tokenStr, claims, err := user.NewAccessToken(sessionID)
 	if err != nil {
 		logger.HighlightedDanger("Error occured while signing access token")
 		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Token Signing Failed", err)
 	}
 
-	logger.Success("New Access Token Service Success")
+	svc.revocationSvc.Track(claims)
 	return tokenStr, nil
 }
 
-func (svc *authenticationService) NewRefreshToken(userID uint) (string, *application_types.ApplicationError) {
+// NewRefreshToken starts a new session (token family) for the user acting in
+// their organization and branch. The returned token is the only copy of the
+// refresh token; the row keeps its SHA-256 hash so that it can be looked up by
+// the presented token.
+func (svc *authenticationService) NewRefreshToken(user *models.User, client dtos.ClientInfo) (string, *models.RefreshToken, *application_types.ApplicationError) {
 	logger.Info("Started New Refresh Token Service")
-	user, appErr := svc.userSvc.FindByID(userID)
-	if appErr != nil {
-		logger.Danger("New Refresh Token Service stopped")
-		return "", appErr
+
+	familyID, err := auth.NewRandomID()
+	if err != nil {
+		logger.HighlightedDanger("Error occured while generating refresh token family")
+		return "", nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Refresh Signing Failed", err)
 	}
 
-	bytes := make([]byte, 35)
-	_, err := rand.Read(bytes)
+	tokenStr, tokenHash, err := auth.NewOpaqueToken()
 	if err != nil {
 		logger.HighlightedDanger("Error occured while generating refresh token")
-		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Refresh Signing Failed", err)
+		return "", nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Refresh Signing Failed", err)
 	}
 
-	tokenStr := base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(bytes)
-	tokenHash, err := bcrypt.GenerateFromPassword([]byte(tokenStr), bcrypt.DefaultCost)
-	if err != nil {
-		logger.HighlightedDanger("Error occured while hashing refresh token")
-		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Refresh Signing Failed", err)
+	session := &models.RefreshToken{
+		UserID:         user.ID,
+		FamilyID:       familyID,
+		OrganizationID: user.OrganizationID,
+		BranchID:       optionalID(user.BranchID),
+		TokenHash:      tokenHash,
+		DeviceName:     client.DeviceName,
+		UserAgent:      client.UserAgent,
+		IPAddress:      client.IPAddress,
+		LastUsedAt:     time.Now(),
+		ExpiresAt:      time.Now().Add(RefreshTokenTTL),
 	}
 
-	if err := svc.db.Create(&models.RefreshToken{UserID: user.ID, TokenHash: string(tokenHash), ExpiresAt: time.Now().Add(7 * (24 * time.Hour))}).Error; err != nil {
+	if err := svc.db.Create(session).Error; err != nil {
 		logger.HighlightedDanger("Error occured while generating refresh token. Gorm Message: " + err.Error())
-		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Refresh Signing Failed.", err)
+		return "", nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Refresh Signing Failed.", err)
 
 	}
 
 	logger.Success("New Refresh Token Service Success")
-	return tokenStr, nil
+	return tokenStr, session, nil
 }
 
-func (svc *authenticationService) RotateRefreshTokenWithNewAccessToken(refreshToken string, userID uint) (access_token, refresh_token string, appErr *application_types.ApplicationError) {
+// RotateRefreshTokenWithNewAccessToken revokes the presented refresh token and
+// issues its successor in the same family. Presenting a token that was already
+// rotated means it was copied, so the whole family is revoked.
+func (svc *authenticationService) RotateRefreshTokenWithNewAccessToken(refreshToken string, userID uint, client dtos.ClientInfo) (access_token, refresh_token string, appErr *application_types.ApplicationError) {
 	logger.Info("Rotate Refresh Token With New Access Token Service Started")
-	user, appErr := svc.userSvc.FindByID(userID)
-	if appErr != nil {
+	var rt models.RefreshToken
+	defer func() { svc.auditRefresh(&rt, client, appErr) }()
+
+	if err := svc.db.Where("token_hash = ?", auth.HashOpaqueToken(refreshToken)).First(&rt).Error; err != nil {
+		logger.Warning("Invalid refresh token. Gorm Message: " + err.Error())
 		logger.Danger("Rotate Refresh Token With New Access Token Service Stopped")
-		return
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", "", application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid Refresh Token", fmt.Errorf("Invalid refresh token"))
+		}
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Invalid Refresh Token", err)
 	}
 
-	access_token, err := user.NewAccessToken()
-	if err != nil {
-		logger.HighlightedDanger("Error occured while signing access token")
+	if rt.IsRevoked() {
+		if rt.RevokedReason == models.RefreshTokenRevokedRotated {
+			return "", "", svc.handleRefreshTokenReuse(&rt, client)
+		}
+		logger.Warning("Revoked refresh token.")
 		logger.Danger("Rotate Refresh Token With New Access Token Service Stopped")
-		appErr = application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Token Signing Failed", err)
-		return
+		return "", "", application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid Refresh Token", fmt.Errorf("Your session has ended. Please login again"))
 	}
 
-	var rt models.RefreshToken
-	if err := svc.db.Where("user_id = ?", user.ID).Last(&rt).Error; err != nil {
-		logger.Warning("Invalid refresh token. Gorm Message: " + err.Error())
+	// user_id in the request body is optional now, but it has to match when given.
+	if userID != 0 && rt.UserID != userID {
+		logger.Warning("Refresh token does not belong to the given user.")
 		logger.Danger("Rotate Refresh Token With New Access Token Service Stopped")
-		appErr = application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid Refresh Token", err)
-
-		return "", "", appErr
+		return "", "", application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid Refresh Token", fmt.Errorf("Invalid refresh token"))
 	}
 
 	if rt.ExpiresAt.Before(time.Now()) {
@@ -215,26 +511,299 @@ func (svc *authenticationService) RotateRefreshTokenWithNewAccessToken(refreshTo
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(rt.TokenHash), []byte(refreshToken)); err != nil {
-		fmt.Println("refresh token: " + refreshToken)
-		logger.Warning("Invalid refresh token while comparing the hash.")
+	user, appErr := svc.userSvc.FindByID(rt.UserID)
+	if appErr != nil {
 		logger.Danger("Rotate Refresh Token With New Access Token Service Stopped")
-		appErr = application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid Refresh Token", err)
-		return "", "", appErr
+		return
+	}
+
+	if appErr = ensureActive(user); appErr != nil {
+		logger.Warning("Rotate Refresh Token With New Access Token Service Stopped. Message: User is inactive")
+		return
+	}
+
+	// The session stays in its organization and branch, unless the user lost
+	// access to them meanwhile.
+	user, appErr = svc.memberSvc.ResolveUser(user, rt.OrganizationIDOrZero(), rt.BranchIDOrZero())
+	if appErr != nil {
+		logger.Danger("Rotate Refresh Token With New Access Token Service Stopped")
+		return
+	}
+
+	refresh_token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Error occured while generating refresh token")
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Refresh Token Generation Failed", err)
+	}
+
+	reused := false
+	err = svc.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", rt.ID).Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": models.RefreshTokenRevokedRotated,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Another request rotated the same token first.
+			reused = true
+			return nil
+		}
+
+		return tx.Create(&models.RefreshToken{
+			UserID:         rt.UserID,
+			FamilyID:       rt.FamilyID,
+			ParentID:       &rt.ID,
+			OrganizationID: user.OrganizationID,
+			BranchID:       optionalID(user.BranchID),
+			TokenHash:      tokenHash,
+			DeviceName:     rt.DeviceName,
+			UserAgent:      client.UserAgent,
+			IPAddress:      client.IPAddress,
+			LastUsedAt:     time.Now(),
+			ExpiresAt:      time.Now().Add(RefreshTokenTTL),
+		}).Error
+	})
+	if err != nil {
+		logger.HighlightedDanger("Unable to rotate refresh token. Gorm Message: " + err.Error())
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Refresh Token Generation Failed", err)
+	}
+	if reused {
+		return "", "", svc.handleRefreshTokenReuse(&rt, client)
 	}
 
-	if err := svc.db.Delete(&rt).Error; err != nil {
-		logger.Warning("Unable to delete existing refresh token")
+	access_token, appErr = svc.signAccessToken(user, rt.FamilyID)
+	if appErr != nil {
 		logger.Danger("Rotate Refresh Token With New Access Token Service Stopped")
-		appErr = application_types.NewApplicationError(false, http.StatusUnauthorized, "Unable to delete existing refresh token. Gorm Message: "+err.Error(), err)
 		return "", "", appErr
 	}
 
-	refresh_token, appErr = svc.NewRefreshToken(user.ID)
+	logger.Success("Rotate Refresh Token With New Access Token Service Success")
+	return access_token, refresh_token, nil
+}
+
+func (svc *authenticationService) handleRefreshTokenReuse(rt *models.RefreshToken, client dtos.ClientInfo) *application_types.ApplicationError {
+	logger.HighlightedDanger("Reuse of a rotated refresh token detected. Revoking the session " + rt.FamilyID)
+	if appErr := svc.sessSvc.RevokeFamily(rt.FamilyID, models.RefreshTokenRevokedReuseDetected); appErr != nil {
+		return appErr
+	}
+
+	svc.securityEventSvc.Record(&models.SecurityEvent{
+		UserID:    rt.UserID,
+		Type:      models.SecurityEventRefreshTokenReuse,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   "Rotated refresh token " + strconv.FormatUint(uint64(rt.ID), 10) + " of the session " + rt.FamilyID + " was presented again. The session is revoked.",
+	})
+
+	return application_types.NewApplicationError(false, http.StatusUnauthorized, "Invalid Refresh Token", fmt.Errorf("Refresh token reuse detected. Please login again"))
+}
+
+// Logout ends the session of the presented refresh token.
+func (svc *authenticationService) Logout(refreshToken string) *application_types.ApplicationError {
+	logger.Info("Logout Service Started")
+	var rt models.RefreshToken
+	if err := svc.db.Where("token_hash = ? AND revoked_at IS NULL", auth.HashOpaqueToken(refreshToken)).First(&rt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("Logout Service Stopped. Message: Unknown refresh token.")
+			return application_types.NewApplicationError(false, http.StatusUnauthorized, "Logout failed", fmt.Errorf("Invalid refresh token"))
+		}
+		logger.HighlightedDanger("Unable to find the session. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Logout failed", err)
+	}
+
+	if appErr := svc.sessSvc.RevokeFamily(rt.FamilyID, models.RefreshTokenRevokedLogout); appErr != nil {
+		logger.Danger("Logout Service Stopped")
+		return appErr
+	}
+
+	logger.Success("Logout Service Success")
+	return nil
+}
+
+// SwitchOrganization moves the session of the access token to another
+// organization of the user and returns an access token for it. The refresh
+// token keeps working and issues tokens for the new organization.
+func (svc *authenticationService) SwitchOrganization(claims *application_types.AccessToken, organizationID uint, client dtos.ClientInfo) (access_token string, appErr *application_types.ApplicationError) {
+	logger.Info("Switch organization service started")
+	user, appErr := svc.findSessionUser(claims)
 	if appErr != nil {
-		logger.HighlightedDanger("Error occured while generation refresh token")
-		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Refresh Token Generation Failed", err)
+		logger.Warning("Switch organization service stopped")
+		return "", appErr
 	}
 
-	return
+	member, appErr := svc.memberSvc.FindMember(organizationID, user.ID)
+	if appErr != nil {
+		logger.Danger("Switch organization service stopped")
+		return "", appErr
+	}
+	branchID, appErr := svc.memberSvc.ResolveBranch(member, 0)
+	if appErr != nil {
+		logger.Danger("Switch organization service stopped")
+		return "", appErr
+	}
+
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", claims.SessionID).
+			Updates(map[string]interface{}{"organization_id": organizationID, "branch_id": optionalID(branchID)}).Error; err != nil {
+			return err
+		}
+		// The next login starts in the organization used last.
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("organization_id", organizationID).Error
+	})
+	if err != nil {
+		logger.HighlightedDanger("Unable to switch the organization of the session. Gorm Message: " + err.Error())
+		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Switch organization failed", err)
+	}
+
+	access_token, appErr = svc.replaceAccessToken(claims, user.InOrganization(member).InBranch(branchID))
+	if appErr != nil {
+		logger.Danger("Switch organization service stopped")
+		return "", appErr
+	}
+
+	event := newAuditEvent(models.AuditActionOrganizationSwitched, models.AuditOutcomeSuccess, dtos.Actor{UserID: user.ID, Client: client})
+	event.OrganizationID = &organizationID
+	event.TargetType, event.TargetID = models.AuditTargetUser, &user.ID
+	event.Changes = map[string]models.AuditChange{"organization_id": {From: claims.OrganizationID, To: organizationID}}
+	svc.auditSvc.Record(event)
+
+	logger.Success("Switch organization service success")
+	return access_token, nil
+}
+
+// SwitchBranch moves the session of the access token to another branch of its
+// organization. The zero branch, every branch, is only allowed to members who
+// are not restricted to some branches.
+func (svc *authenticationService) SwitchBranch(claims *application_types.AccessToken, branchID uint, client dtos.ClientInfo) (access_token string, appErr *application_types.ApplicationError) {
+	logger.Info("Switch branch service started")
+	user, appErr := svc.findSessionUser(claims)
+	if appErr != nil {
+		logger.Warning("Switch branch service stopped")
+		return "", appErr
+	}
+
+	member, appErr := svc.memberSvc.FindMember(claims.OrganizationID, user.ID)
+	if appErr != nil {
+		logger.Danger("Switch branch service stopped")
+		return "", appErr
+	}
+	if resolved, appErr := svc.memberSvc.ResolveBranch(member, branchID); appErr != nil {
+		logger.Danger("Switch branch service stopped")
+		return "", appErr
+	} else if resolved != branchID {
+		logger.Warning("Switch branch service stopped. Message: Branch not allowed for the member")
+		return "", application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("You cannot work in the branch"))
+	}
+
+	if err := svc.db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", claims.SessionID).
+		Update("branch_id", optionalID(branchID)).Error; err != nil {
+		logger.HighlightedDanger("Unable to switch the branch of the session. Gorm Message: " + err.Error())
+		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "Switch branch failed", err)
+	}
+
+	access_token, appErr = svc.replaceAccessToken(claims, user.InOrganization(member).InBranch(branchID))
+	if appErr != nil {
+		logger.Danger("Switch branch service stopped")
+		return "", appErr
+	}
+
+	event := newAuditEvent(models.AuditActionBranchSwitched, models.AuditOutcomeSuccess, dtos.Actor{UserID: user.ID, Client: client})
+	event.OrganizationID = &member.OrganizationID
+	event.TargetType, event.TargetID = models.AuditTargetUser, &user.ID
+	event.Changes = map[string]models.AuditChange{"branch_id": {From: claims.BranchID, To: branchID}}
+	svc.auditSvc.Record(event)
+
+	logger.Success("Switch branch service success")
+	return access_token, nil
+}
+
+// findSessionUser returns the active user of a login session. Impersonation
+// tokens have no session to switch.
+func (svc *authenticationService) findSessionUser(claims *application_types.AccessToken) (*models.User, *application_types.ApplicationError) {
+	if claims == nil || claims.Act != nil || claims.SessionID == "" {
+		return nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("Only a login session can be switched"))
+	}
+
+	user, appErr := svc.userSvc.FindByID(claims.UserID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if appErr = ensureActive(user); appErr != nil {
+		return nil, appErr
+	}
+	return user, nil
+}
+
+// replaceAccessToken signs the access token of the session for the user and
+// revokes the presented one, which still acts in the previous organization or
+// branch.
+func (svc *authenticationService) replaceAccessToken(claims *application_types.AccessToken, user *models.User) (string, *application_types.ApplicationError) {
+	access_token, appErr := svc.signAccessToken(user, claims.SessionID)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	svc.revocationSvc.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+	return access_token, nil
+}
+
+func ensureActive(user *models.User) *application_types.ApplicationError {
+	if user.IsActive() {
+		return nil
+	}
+	return application_types.NewApplicationError(false, http.StatusForbidden, "Account is inactive",
+		fmt.Errorf("Your account is deactivated. Please contact your administrator"))
+}
+
+// auditLogin records the outcome of a login attempt. A login waiting for MFA is
+// recorded once the MFA code is verified.
+func (svc *authenticationService) auditLogin(method, identifier string, userID uint, client dtos.ClientInfo, mfaPending bool, appErr *application_types.ApplicationError) {
+	if appErr == nil && mfaPending {
+		return
+	}
+
+	action, outcome := models.AuditActionLoginSucceeded, models.AuditOutcomeSuccess
+	if appErr != nil {
+		action, outcome = models.AuditActionLoginFailed, models.AuditOutcomeFailure
+		if appErr.GetHTTPStatusCode() == http.StatusTooManyRequests {
+			action = models.AuditActionLoginLockedOut
+		}
+	}
+
+	details := method + " login"
+	if identifier != "" {
+		details += " with " + identifier
+	}
+	if appErr != nil {
+		details += ". " + appErr.GetErrorMessage()
+	}
+	svc.recordAudit(action, outcome, userID, client, details)
+}
+
+func (svc *authenticationService) auditRefresh(rt *models.RefreshToken, client dtos.ClientInfo, appErr *application_types.ApplicationError) {
+	if appErr == nil {
+		svc.recordAudit(models.AuditActionRefreshTokenRotated, models.AuditOutcomeSuccess, rt.UserID, client, "Session "+rt.FamilyID)
+		return
+	}
+
+	details := appErr.GetErrorMessage()
+	if rt.FamilyID != "" {
+		details = "Session " + rt.FamilyID + ". " + details
+	}
+	svc.recordAudit(models.AuditActionRefreshTokenRejected, models.AuditOutcomeFailure, rt.UserID, client, details)
+}
+
+// recordAudit records an authentication event of the user, who is both the
+// actor and the target. userID is zero when the user is not known.
+func (svc *authenticationService) recordAudit(action, outcome string, userID uint, client dtos.ClientInfo, details string) {
+	event := newAuditEvent(action, outcome, dtos.Actor{UserID: userID, Client: client})
+	if userID != 0 {
+		event.TargetType, event.TargetID = models.AuditTargetUser, &userID
+	}
+	event.Details = details
+	svc.auditSvc.Record(event)
 }
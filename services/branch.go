@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type branchService struct {
+	db *gorm.DB
+	// organizationID limits every query to the organization, see ForOrganization.
+	organizationID uint
+}
+
+type BranchService interface {
+	ForOrganization(organizationID uint) BranchService
+	Create(branchDTO *dtos.BranchDTO) (*models.Branch, *application_types.ApplicationError)
+	Find() ([]*models.Branch, *application_types.ApplicationError)
+	FindByID(id uint) (*models.Branch, *application_types.ApplicationError)
+	UpdateByID(id uint, branchDTO *dtos.BranchDTO) (*models.Branch, *application_types.ApplicationError)
+	DeleteByID(id uint) *application_types.ApplicationError
+}
+
+func NewBranchService() BranchService {
+	return &branchService{
+		db: db.Get(),
+	}
+}
+
+// ForOrganization returns the service limited to the branches of the
+// organization. The zero organization sees every branch but cannot create one.
+func (svc *branchService) ForOrganization(organizationID uint) BranchService {
+	scoped := *svc
+	scoped.organizationID = organizationID
+	return &scoped
+}
+
+func (svc *branchService) scoped() *gorm.DB {
+	return svc.db.Scopes(models.ScopeOrganization(svc.organizationID))
+}
+
+func (svc *branchService) Create(branchDTO *dtos.BranchDTO) (*models.Branch, *application_types.ApplicationError) {
+	logger.Info("Creating a new branch.")
+	if svc.organizationID == 0 {
+		logger.Warning("Branch creation stopped. Message: No organization")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Branch creation failed",
+			fmt.Errorf("Branches are created within an organization"))
+	}
+
+	branch := &models.Branch{OrganizationID: svc.organizationID, NextInvoiceNumber: 1}
+	applyBranchDTO(branch, branchDTO)
+
+	if err := branch.ValidateFields(); err != nil {
+		appErr := application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request",
+			fmt.Errorf("Validation failed for creating the branch. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(branch).Error; err != nil {
+			return err
+		}
+		return claimInvoicePrefix(tx, branch)
+	})
+	if errors.Is(err, errInvoicePrefixUsed) {
+		return nil, invoicePrefixUsedError(branch)
+	}
+	if err != nil {
+		logger.Danger("Branch creation failed. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch creation failed", err)
+	}
+
+	logger.Success("Branch created succesfully.")
+	return branch, nil
+}
+
+func (svc *branchService) Find() ([]*models.Branch, *application_types.ApplicationError) {
+	logger.Info("Finding branches")
+	var branches []*models.Branch
+	if err := svc.scoped().Order("name").Find(&branches).Error; err != nil {
+		logger.Danger("Unable to find branches. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch find failed!", err)
+	}
+
+	logger.Success("Branches found successfully")
+	return branches, nil
+}
+
+func (svc *branchService) FindByID(id uint) (*models.Branch, *application_types.ApplicationError) {
+	branch := &models.Branch{}
+	if err := svc.scoped().First(branch, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Danger("No branch found for the id " + strconv.FormatUint(uint64(id), 10))
+			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No branch found for the given id", err)
+		}
+		logger.Danger("Unable to find branch by id. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find branch with id", err)
+	}
+
+	return branch, nil
+}
+
+// UpdateByID changes the given fields of the branch. The next invoice number
+// can only move back together with a prefix never used before, so that numbers
+// never repeat.
+func (svc *branchService) UpdateByID(id uint, branchDTO *dtos.BranchDTO) (*models.Branch, *application_types.ApplicationError) {
+	logger.Info("Updating the branch id " + strconv.FormatUint(uint64(id), 10))
+	branch, appErr := svc.FindByID(id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	previous := *branch
+
+	applyBranchDTO(branch, branchDTO)
+
+	if err := branch.ValidateFields(); err != nil {
+		appErr = application_types.NewApplicationError(false, http.StatusBadRequest, "Branch update failed",
+			fmt.Errorf("Validation failed. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	if branch.NextInvoiceNumber < previous.NextInvoiceNumber && branch.InvoicePrefix == previous.InvoicePrefix {
+		logger.Warning("Branch update stopped. Message: The invoice series would repeat")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Branch update failed",
+			fmt.Errorf("The next invoice number cannot go back without a new invoice prefix")).AddFieldErrors("next_invoice_number", "must not be lower than "+strconv.FormatUint(uint64(previous.NextInvoiceNumber), 10))
+	}
+
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if branch.InvoicePrefix != previous.InvoicePrefix {
+			if err := claimInvoicePrefix(tx, branch); err != nil {
+				return err
+			}
+		}
+		return tx.Save(branch).Error
+	})
+	if errors.Is(err, errInvoicePrefixUsed) {
+		return nil, invoicePrefixUsedError(branch)
+	}
+	if err != nil {
+		logger.Danger("Unable to update the branch. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch update failed.", err)
+	}
+
+	logger.Success("Branch updated by id " + strconv.FormatUint(uint64(id), 10))
+	return branch, nil
+}
+
+// DeleteByID deletes the branch. Members restricted to the branch alone have to
+// be given another branch first, so that they do not end up in every branch.
+func (svc *branchService) DeleteByID(id uint) *application_types.ApplicationError {
+	logger.Info("Deleting the branch id " + strconv.FormatUint(uint64(id), 10))
+	branch, appErr := svc.FindByID(id)
+	if appErr != nil {
+		return appErr
+	}
+
+	var onlyBranchMembers int64
+	err := svc.db.Table("organization_member_branches AS mb").Where("mb.branch_id = ?", id).
+		Where("(SELECT COUNT(*) FROM organization_member_branches o WHERE o.organization_member_id = mb.organization_member_id) = 1").
+		Count(&onlyBranchMembers).Error
+	if err != nil {
+		logger.Danger("Unable to count the members of the branch. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch deletion failed", err)
+	}
+	if onlyBranchMembers > 0 {
+		logger.Warning("Branch deletion stopped. Message: Members are restricted to the branch")
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Branch deletion failed",
+			fmt.Errorf("%d member(s) can only work in this branch. Assign them another branch first", onlyBranchMembers))
+	}
+
+	err = svc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM organization_member_branches WHERE branch_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(branch).Error
+	})
+	if err != nil {
+		logger.Danger("Unable to delete the branch. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch deletion failed", err)
+	}
+
+	logger.Success("Deleted the branch id " + strconv.FormatUint(uint64(id), 10))
+	return nil
+}
+
+var errInvoicePrefixUsed = errors.New("invoice prefix already used")
+
+// claimInvoicePrefix records the prefix of the branch. The unique index of the
+// prefixes of an organization keeps the invoice series apart, even across
+// concurrent requests.
+func claimInvoicePrefix(tx *gorm.DB, branch *models.Branch) error {
+	prefix := &models.BranchInvoicePrefix{OrganizationID: branch.OrganizationID, Prefix: branch.InvoicePrefix, BranchID: branch.ID}
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(prefix)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errInvoicePrefixUsed
+	}
+	return nil
+}
+
+// invoicePrefixUsedError reports the taken prefix. The empty prefix is a
+// series of its own too, so a branch left without a prefix while another one
+// already is, is asked for a prefix instead.
+func invoicePrefixUsedError(branch *models.Branch) *application_types.ApplicationError {
+	if branch.InvoicePrefix == "" {
+		logger.Warning("Branch without an invoice prefix stopped. Message: Another branch has no invoice prefix")
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invoice prefix required",
+			fmt.Errorf("Another branch of the organization already numbers its invoices without a prefix")).AddFieldErrors("invoice_prefix", "is required, a second branch needs its own invoice prefix")
+	}
+	logger.Warning("Given invoice prefix already used")
+	return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invalid invoice prefix",
+		fmt.Errorf("The invoice prefix '%s' is or was used by a branch of the organization", branch.InvoicePrefix)).AddFieldErrors("invoice_prefix", "is already taken")
+}
+
+func applyBranchDTO(branch *models.Branch, branchDTO *dtos.BranchDTO) {
+	if strings.TrimSpace(branchDTO.Name) != "" {
+		branch.Name = strings.TrimSpace(branchDTO.Name)
+	}
+	if branchDTO.Address != nil {
+		branch.Address = newAddress(branchDTO.Address)
+	}
+	if strings.TrimSpace(branchDTO.GSTIN) != "" {
+		branch.GSTIN = strings.ToUpper(strings.TrimSpace(branchDTO.GSTIN))
+	}
+	if branchDTO.InvoicePrefix != nil {
+		branch.InvoicePrefix = strings.TrimSpace(*branchDTO.InvoicePrefix)
+	}
+	if branchDTO.InvoiceNumberPadding != nil {
+		branch.InvoiceNumberPadding = *branchDTO.InvoiceNumberPadding
+	}
+	if branchDTO.NextInvoiceNumber != nil {
+		branch.NextInvoiceNumber = *branchDTO.NextInvoiceNumber
+	}
+}
+
+func newAddress(addressDTO *dtos.AddressDTO) models.Address {
+	return models.Address{
+		Line1:      strings.TrimSpace(addressDTO.Line1),
+		Line2:      strings.TrimSpace(addressDTO.Line2),
+		City:       strings.TrimSpace(addressDTO.City),
+		State:      strings.TrimSpace(addressDTO.State),
+		PostalCode: strings.TrimSpace(addressDTO.PostalCode),
+		Country:    strings.TrimSpace(addressDTO.Country),
+	}
+}
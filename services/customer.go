@@ -0,0 +1,332 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type customerService struct {
+	auditSvc AuditEventService
+	db       *gorm.DB
+	// organizationID limits every query to the organization, see ForOrganization.
+	organizationID uint
+	// branchID limits every query to the branch, see ForBranch.
+	branchID uint
+}
+
+type CustomerService interface {
+	ForOrganization(organizationID uint) CustomerService
+	ForBranch(branchID uint) CustomerService
+	Create(customerDTO *dtos.CustomerDTO, actor dtos.Actor) (*models.Customer, *application_types.ApplicationError)
+	Find(filter models.CustomerFilter) ([]*models.Customer, int64, *application_types.ApplicationError)
+	FindByID(id uint) (*models.Customer, *application_types.ApplicationError)
+	UpdateByID(id uint, customerDTO *dtos.CustomerDTO, actor dtos.Actor) (*models.Customer, *application_types.ApplicationError)
+	DeleteByID(id uint, actor dtos.Actor) *application_types.ApplicationError
+}
+
+func NewCustomerService() CustomerService {
+	return &customerService{
+		auditSvc: NewAuditEventService(),
+		db:       db.Get(),
+	}
+}
+
+// ForOrganization returns the service limited to the customers of the
+// organization. The zero organization sees every customer but cannot create one.
+func (svc *customerService) ForOrganization(organizationID uint) CustomerService {
+	scoped := *svc
+	scoped.organizationID = organizationID
+	return &scoped
+}
+
+// ForBranch returns the service limited to the customers of the branch and the
+// shared ones. New customers belong to the branch. The zero branch sees every
+// customer and creates shared ones.
+func (svc *customerService) ForBranch(branchID uint) CustomerService {
+	scoped := *svc
+	scoped.branchID = branchID
+	return &scoped
+}
+
+func (svc *customerService) scoped() *gorm.DB {
+	return svc.db.Scopes(models.ScopeOrganization(svc.organizationID), models.ScopeBranch(svc.branchID))
+}
+
+func (svc *customerService) Create(customerDTO *dtos.CustomerDTO, actor dtos.Actor) (*models.Customer, *application_types.ApplicationError) {
+	logger.Info("Creating a new customer.")
+	if svc.organizationID == 0 {
+		logger.Warning("Customer creation stopped. Message: No organization")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Customer creation failed",
+			fmt.Errorf("Customers are created within an organization"))
+	}
+
+	customer := &models.Customer{OrganizationID: svc.organizationID, BranchID: optionalID(svc.branchID), DefaultCurrency: models.DefaultCurrency}
+	applyCustomerDTO(customer, customerDTO)
+
+	logger.Info("Validating new customer fields.")
+	if err := customer.ValidateFields(); err != nil {
+		appErr := application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request",
+			fmt.Errorf("Validation failed for creating the customer. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	// Contacts and addresses are created with the customer.
+	if err := svc.db.Create(customer).Error; err != nil {
+		appErr := application_types.NewApplicationError(false, http.StatusInternalServerError, "Customer creation failed",
+			fmt.Errorf("Customer creation failed. Message: %w", err))
+		logger.Danger(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	event := newAuditEvent(models.AuditActionCustomerCreated, models.AuditOutcomeSuccess, actor)
+	event.OrganizationID = &customer.OrganizationID
+	event.TargetType, event.TargetID = models.AuditTargetCustomer, &customer.ID
+	event.Changes = customerAuditChanges(&models.Customer{}, customer)
+	svc.auditSvc.Record(event)
+
+	logger.Success("Customer created succesfully.")
+	return customer, nil
+}
+
+// Find returns a page of the customers matching the filter, ordered by their
+// display name, with the total number of matches.
+func (svc *customerService) Find(filter models.CustomerFilter) ([]*models.Customer, int64, *application_types.ApplicationError) {
+	logger.Info("Finding customers")
+	query := svc.scoped().Model(&models.Customer{})
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		logger.Info("Added search filter to the customer find query")
+		pattern := "%" + search + "%"
+		contacts := svc.db.Model(&models.CustomerContact{}).Select("customer_id").
+			Where("name ILIKE ? OR email ILIKE ? OR phone LIKE ?", pattern, pattern, pattern)
+		query = query.Where("legal_name ILIKE ? OR display_name ILIKE ? OR gstin ILIKE ? OR pan ILIKE ? OR id IN (?)",
+			pattern, pattern, pattern, pattern, contacts)
+	}
+
+	if strings.TrimSpace(filter.GSTIN) != "" {
+		logger.Info("Added GSTIN filter to the customer find query")
+		query = query.Where("gstin = ?", strings.ToUpper(strings.TrimSpace(filter.GSTIN)))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Danger("Unable to count customers. Message: " + err.Error())
+		return nil, 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Customer find failed!", err)
+	}
+
+	var customers []*models.Customer
+	err := query.Preload("Contacts").Preload("Addresses").Order("display_name, id").
+		Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).Find(&customers).Error
+	if err != nil {
+		logger.Danger("Unable to find customers. Message: " + err.Error())
+		return nil, 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Customer find failed!", err)
+	}
+
+	logger.Success("Customers found successfully")
+	return customers, total, nil
+}
+
+func (svc *customerService) FindByID(id uint) (*models.Customer, *application_types.ApplicationError) {
+	customer := &models.Customer{}
+	if err := svc.scoped().Preload("Contacts").Preload("Addresses").First(customer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Danger("No customer found for the id " + strconv.FormatUint(uint64(id), 10))
+			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No customer found for the given id", err)
+		}
+		logger.Danger("Unable to find customer by id. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find customer with id", err)
+	}
+
+	logger.Success("Customer found by id!")
+	return customer, nil
+}
+
+func (svc *customerService) UpdateByID(id uint, customerDTO *dtos.CustomerDTO, actor dtos.Actor) (*models.Customer, *application_types.ApplicationError) {
+	logger.Info("Started updating customer by id " + strconv.FormatUint(uint64(id), 10))
+	customer, appErr := svc.FindByID(id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	previous := *customer
+
+	applyCustomerDTO(customer, customerDTO)
+
+	if err := customer.ValidateFields(); err != nil {
+		appErr = application_types.NewApplicationError(false, http.StatusBadRequest, "Customer update failed",
+			fmt.Errorf("Validation failed. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(customer).Error; err != nil {
+			return err
+		}
+
+		if customerDTO.Contacts != nil {
+			if err := tx.Where("customer_id = ?", id).Delete(&models.CustomerContact{}).Error; err != nil {
+				return err
+			}
+			for _, contact := range customer.Contacts {
+				contact.CustomerID = id
+			}
+			if len(customer.Contacts) > 0 {
+				if err := tx.Create(customer.Contacts).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if customerDTO.Addresses != nil {
+			if err := tx.Where("customer_id = ?", id).Delete(&models.CustomerAddress{}).Error; err != nil {
+				return err
+			}
+			for _, address := range customer.Addresses {
+				address.CustomerID = id
+			}
+			if len(customer.Addresses) > 0 {
+				if err := tx.Create(customer.Addresses).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		appErr = application_types.NewApplicationError(false, http.StatusInternalServerError, "Customer update failed.",
+			fmt.Errorf("Error occured while updating customer. Message: %w", err))
+		logger.Danger(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	changes := customerAuditChanges(&previous, customer)
+	if customerDTO.Contacts != nil {
+		changes["contacts"] = models.AuditChange{From: len(previous.Contacts), To: len(customer.Contacts)}
+	}
+	if customerDTO.Addresses != nil {
+		changes["addresses"] = models.AuditChange{From: len(previous.Addresses), To: len(customer.Addresses)}
+	}
+	if len(changes) > 0 {
+		event := newAuditEvent(models.AuditActionCustomerUpdated, models.AuditOutcomeSuccess, actor)
+		event.OrganizationID = &customer.OrganizationID
+		event.TargetType, event.TargetID = models.AuditTargetCustomer, &customer.ID
+		event.Changes = changes
+		svc.auditSvc.Record(event)
+	}
+
+	logger.Success("Customer updated by id " + strconv.FormatUint(uint64(id), 10))
+	return customer, nil
+}
+
+func (svc *customerService) DeleteByID(id uint, actor dtos.Actor) *application_types.ApplicationError {
+	logger.Info("Deleting a customer with id " + strconv.FormatUint(uint64(id), 10))
+	customer, appErr := svc.FindByID(id)
+	if appErr != nil {
+		return appErr
+	}
+
+	if err := svc.db.Delete(customer).Error; err != nil {
+		appErr = application_types.NewApplicationError(false, http.StatusInternalServerError, "Customer delete failed.",
+			fmt.Errorf("Unable to delete customer of id %d. Message: %w", id, err))
+		logger.Danger(appErr.GetErrorMessage())
+		return appErr
+	}
+
+	event := newAuditEvent(models.AuditActionCustomerDeleted, models.AuditOutcomeSuccess, actor)
+	event.OrganizationID = &customer.OrganizationID
+	event.TargetType, event.TargetID = models.AuditTargetCustomer, &customer.ID
+	event.Details = "Deleted the customer " + customer.DisplayName
+	svc.auditSvc.Record(event)
+
+	logger.Success("Deleted customer with id " + strconv.FormatUint(uint64(id), 10))
+	return nil
+}
+
+// applyCustomerDTO copies the given fields. The display name defaults to the
+// legal name and the PAN to the one inside the GSTIN, also when only the GSTIN
+// changes. An empty GSTIN or PAN clears it.
+func applyCustomerDTO(customer *models.Customer, customerDTO *dtos.CustomerDTO) {
+	if strings.TrimSpace(customerDTO.LegalName) != "" {
+		customer.LegalName = strings.TrimSpace(customerDTO.LegalName)
+	}
+	if strings.TrimSpace(customerDTO.DisplayName) != "" {
+		customer.DisplayName = strings.TrimSpace(customerDTO.DisplayName)
+	}
+	if customer.DisplayName == "" {
+		customer.DisplayName = customer.LegalName
+	}
+	gstinChanged := false
+	if customerDTO.GSTIN != nil {
+		gstin := strings.ToUpper(strings.TrimSpace(*customerDTO.GSTIN))
+		gstinChanged = gstin != customer.GSTIN
+		customer.GSTIN = gstin
+	}
+	if customerDTO.PAN != nil {
+		customer.PAN = strings.ToUpper(strings.TrimSpace(*customerDTO.PAN))
+	} else if (gstinChanged || customer.PAN == "") && len(customer.GSTIN) == 15 {
+		customer.PAN = customer.GSTIN[2:12]
+	}
+	if customerDTO.PaymentTermsDays != nil {
+		customer.PaymentTermsDays = *customerDTO.PaymentTermsDays
+	}
+	if strings.TrimSpace(customerDTO.DefaultCurrency) != "" {
+		customer.DefaultCurrency = strings.ToUpper(strings.TrimSpace(customerDTO.DefaultCurrency))
+	}
+	if customerDTO.Notes != nil {
+		customer.Notes = strings.TrimSpace(*customerDTO.Notes)
+	}
+
+	if customerDTO.Contacts != nil {
+		customer.Contacts = make([]*models.CustomerContact, 0, len(*customerDTO.Contacts))
+		for _, contactDTO := range *customerDTO.Contacts {
+			customer.Contacts = append(customer.Contacts, &models.CustomerContact{
+				Name:        strings.TrimSpace(contactDTO.Name),
+				Email:       strings.TrimSpace(contactDTO.Email),
+				Phone:       strings.TrimSpace(contactDTO.Phone),
+				Designation: strings.TrimSpace(contactDTO.Designation),
+				Primary:     contactDTO.Primary,
+			})
+		}
+	}
+
+	if customerDTO.Addresses != nil {
+		customer.Addresses = make([]*models.CustomerAddress, 0, len(*customerDTO.Addresses))
+		for _, addressDTO := range *customerDTO.Addresses {
+			customer.Addresses = append(customer.Addresses, &models.CustomerAddress{
+				Type:    strings.ToLower(strings.TrimSpace(addressDTO.Type)),
+				Address: newAddress(&addressDTO.Address),
+				Default: addressDTO.Default,
+			})
+		}
+	}
+}
+
+func customerAuditChanges(before, after *models.Customer) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for field, values := range map[string][2]interface{}{
+		"legal_name":         {before.LegalName, after.LegalName},
+		"display_name":       {before.DisplayName, after.DisplayName},
+		"gstin":              {before.GSTIN, after.GSTIN},
+		"pan":                {before.PAN, after.PAN},
+		"payment_terms_days": {before.PaymentTermsDays, after.PaymentTermsDays},
+		"default_currency":   {before.DefaultCurrency, after.DefaultCurrency},
+	} {
+		if values[0] != values[1] {
+			changes[field] = models.AuditChange{From: values[0], To: values[1]}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/mailer"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultEmailVerificationTokenTTL = 24 * time.Hour
+	// Minimum time between two verification emails for the same user.
+	emailVerificationResendInterval = time.Minute
+)
+
+type emailVerificationService struct {
+	userSvc UserService
+	mailer  mailer.Mailer
+	db      *gorm.DB
+}
+
+type EmailVerificationService interface {
+	SendVerification(user *models.User) *application_types.ApplicationError
+	ResendVerification(email string) *application_types.ApplicationError
+	VerifyEmail(token string) *application_types.ApplicationError
+}
+
+func NewEmailVerificationService() EmailVerificationService {
+	return &emailVerificationService{
+		userSvc: NewUserService(),
+		mailer:  mailer.New(),
+		db:      db.Get(),
+	}
+}
+
+// SendVerification emails a new verification link to the user. Earlier links
+// of the user stop working.
+func (svc *emailVerificationService) SendVerification(user *models.User) *application_types.ApplicationError {
+	logger.Info("Send email verification service started")
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate email verification token. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Email verification failed", err)
+	}
+
+	err = svc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.EmailVerificationToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.EmailVerificationToken{
+			UserID:    user.ID,
+			Email:     user.Email,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(emailVerificationTokenTTL()),
+		}).Error
+	})
+	if err != nil {
+		logger.HighlightedDanger("Unable to store email verification token. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Email verification failed", err)
+	}
+
+	link := os.Getenv("APP_BASE_URL") + "/verify-email?token=" + token
+	body := "Hi " + user.Name + ",\n\n" +
+		"Please confirm your email address for Treeforms Billing by opening the link below:\n\n" +
+		link + "\n\n" +
+		"The link expires in " + emailVerificationTokenTTL().String() + ". If you did not sign up, you can ignore this email.\n"
+
+	if err := svc.mailer.Send(user.Email, "Verify your email for Treeforms Billing", body); err != nil {
+		logger.HighlightedDanger("Unable to send email verification email. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Email verification failed", err)
+	}
+
+	logger.Success("Send email verification service success")
+	return nil
+}
+
+func (svc *emailVerificationService) ResendVerification(email string) *application_types.ApplicationError {
+	logger.Info("Resend email verification service started")
+	user, appErr := svc.userSvc.FindByEmail(email)
+	if appErr != nil {
+		if errors.Is(appErr.GetError(), gorm.ErrRecordNotFound) {
+			// Do not reveal whether the email is registered.
+			logger.Warning("Email verification requested for an unregistered email")
+			return nil
+		}
+		return appErr
+	}
+
+	if user.IsEmailVerified() {
+		logger.Warning("Resend email verification service stopped. Message: Email already verified.")
+		return nil
+	}
+
+	var latest models.EmailVerificationToken
+	err := svc.db.Where("user_id = ?", user.ID).Order("created_at DESC").First(&latest).Error
+	if err == nil && time.Since(latest.CreatedAt) < emailVerificationResendInterval {
+		logger.Warning("Resend email verification service stopped. Message: Requested too soon.")
+		return application_types.NewApplicationError(false, http.StatusTooManyRequests, "Email verification failed",
+			fmt.Errorf("A verification email was sent recently. Please wait a minute before requesting another one")).
+			SetHeader("Retry-After", strconv.Itoa(int(emailVerificationResendInterval.Seconds())))
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Danger("Unable to find email verification token. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Email verification failed", err)
+	}
+
+	return svc.SendVerification(user)
+}
+
+func (svc *emailVerificationService) VerifyEmail(token string) *application_types.ApplicationError {
+	logger.Info("Verify email service started")
+	var verificationToken models.EmailVerificationToken
+	if err := svc.db.Where("token_hash = ? AND used_at IS NULL", auth.HashOpaqueToken(token)).First(&verificationToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("Invalid or already used email verification token")
+			return application_types.NewApplicationError(false, http.StatusUnauthorized, "Email verification failed", fmt.Errorf("Invalid or already used verification token"))
+		}
+		logger.HighlightedDanger("Unable to find email verification token. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Email verification failed", err)
+	}
+
+	if verificationToken.ExpiresAt.Before(time.Now()) {
+		logger.Warning("Expired email verification token.")
+		return application_types.NewApplicationError(false, http.StatusUnauthorized, "Email verification failed", fmt.Errorf("Your verification link is expired. Please request a new one"))
+	}
+
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&verificationToken).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		// The email may have changed since the link was sent.
+		res := tx.Model(&models.User{}).Where("id = ? AND email = ?", verificationToken.UserID, verificationToken.Email).Update("email_verified_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warning("Email verification token does not match the current email of the user")
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Email verification failed", fmt.Errorf("The email address of the account has changed. Please request a new verification link"))
+	} else if err != nil {
+		logger.HighlightedDanger("Unable to verify email. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Email verification failed", err)
+	}
+
+	logger.Success("Verify email service success for the user id " + strconv.FormatUint(uint64(verificationToken.UserID), 10))
+	return nil
+}
+
+func emailVerificationTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultEmailVerificationTokenTTL
+	}
+	return ttl
+}
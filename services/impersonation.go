@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+const defaultImpersonationTokenTTL = 15 * time.Minute
+
+type impersonationService struct {
+	userSvc          UserService
+	roleSvc          RoleService
+	memberSvc        OrganizationMemberService
+	revocationSvc    TokenRevocationService
+	securityEventSvc SecurityEventService
+	db               *gorm.DB
+}
+
+type ImpersonationService interface {
+	Impersonate(impersonatorID, organizationID, userID uint, reason string, client dtos.ClientInfo) (string, *application_types.AccessToken, *application_types.ApplicationError)
+	Stop(claims *application_types.AccessToken, client dtos.ClientInfo) *application_types.ApplicationError
+	RecordRequest(entry *models.ImpersonationAuditLog)
+}
+
+func NewImpersonationService() ImpersonationService {
+	return &impersonationService{
+		userSvc:          NewUserService(),
+		roleSvc:          NewRoleService(),
+		memberSvc:        NewOrganizationMemberService(),
+		revocationSvc:    NewTokenRevocationService(),
+		securityEventSvc: NewSecurityEventService(),
+		db:               db.Get(),
+	}
+}
+
+// Impersonate issues a short lived access token for the user with the
+// impersonator in its act claim. Within an organization the roles of both are
+// their roles in it. No refresh token is issued, so the impersonation ends when
+// the token expires.
+func (svc *impersonationService) Impersonate(impersonatorID, organizationID, userID uint, reason string, client dtos.ClientInfo) (string, *application_types.AccessToken, *application_types.ApplicationError) {
+	logger.Info("Impersonation service started")
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		logger.Warning("Impersonation service stopped. Message: No reason given")
+		return "", nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Impersonation failed",
+			fmt.Errorf("A reason is required to impersonate a user")).AddFieldErrors("reason", "is required")
+	}
+
+	if impersonatorID == userID {
+		return "", nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Impersonation failed", fmt.Errorf("You cannot impersonate yourself"))
+	}
+
+	userSvc, roleSvc := svc.userSvc.ForOrganization(organizationID), svc.roleSvc.ForOrganization(organizationID)
+	impersonator, appErr := userSvc.FindByID(impersonatorID)
+	if appErr != nil {
+		logger.Danger("Impersonation service stopped")
+		return "", nil, appErr
+	}
+	permissions, appErr := roleSvc.Permissions(impersonator.Role)
+	if appErr != nil {
+		logger.Danger("Impersonation service stopped")
+		return "", nil, appErr
+	}
+	if !models.HasPermission(permissions, models.PermissionUserImpersonate) {
+		logger.Warning("Impersonation service stopped. Message: Missing the impersonation permission")
+		return "", nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied", fmt.Errorf("Your role cannot impersonate users"))
+	}
+
+	// Impersonators inside an organization may only impersonate its members.
+	user, appErr := userSvc.FindByID(userID)
+	if appErr != nil {
+		logger.Danger("Impersonation service stopped")
+		return "", nil, appErr
+	}
+	// Platform administrators see the user in the organization they used last.
+	user, appErr = svc.memberSvc.ResolveUser(user, organizationID, 0)
+	if appErr != nil {
+		logger.Danger("Impersonation service stopped")
+		return "", nil, appErr
+	}
+	targetPermissions, appErr := svc.roleSvc.ForOrganization(user.OrganizationIDOrZero()).Permissions(user.Role)
+	if appErr != nil {
+		logger.Danger("Impersonation service stopped")
+		return "", nil, appErr
+	}
+	if models.HasPermission(targetPermissions, models.PermissionUserImpersonate) {
+		logger.Warning("Impersonation service stopped. Message: Impersonators cannot be impersonated")
+		return "", nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied", fmt.Errorf("Users who can impersonate cannot be impersonated"))
+	}
+
+	tokenStr, claims, err := user.NewImpersonationAccessToken(impersonator, impersonationTokenTTL())
+	if err != nil {
+		logger.HighlightedDanger("Error occured while signing impersonation token")
+		return "", nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Access Token Signing Failed", err)
+	}
+	svc.revocationSvc.Track(claims)
+
+	svc.securityEventSvc.Record(&models.SecurityEvent{
+		UserID:    user.ID,
+		Type:      models.SecurityEventImpersonationStarted,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details: "Impersonated by the user id " + strconv.FormatUint(uint64(impersonator.ID), 10) + " with the role " + impersonator.Role +
+			" with the token " + claims.ID + " until " + claims.ExpiresAt.Format(time.RFC3339) + ". Reason: " + reason,
+	})
+
+	logger.Success("Impersonation service success")
+	return tokenStr, claims, nil
+}
+
+// Stop revokes the impersonation token used for the request.
+func (svc *impersonationService) Stop(claims *application_types.AccessToken, client dtos.ClientInfo) *application_types.ApplicationError {
+	logger.Info("Stop impersonation service started")
+	if claims == nil || claims.Act == nil {
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Stop impersonation failed", fmt.Errorf("The request is not made while impersonating"))
+	}
+
+	svc.revocationSvc.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+	svc.securityEventSvc.Record(&models.SecurityEvent{
+		UserID:    claims.UserID,
+		Type:      models.SecurityEventImpersonationStopped,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   "Impersonation by the user id " + strconv.FormatUint(uint64(claims.Act.UserID), 10) + " with the token " + claims.ID + " stopped.",
+	})
+
+	logger.Success("Stop impersonation service success")
+	return nil
+}
+
+// RecordRequest adds a request made while impersonating to the audit log.
+func (svc *impersonationService) RecordRequest(entry *models.ImpersonationAuditLog) {
+	if err := svc.db.Create(entry).Error; err != nil {
+		logger.HighlightedDanger("Unable to record the impersonated request. Gorm Message: " + err.Error())
+	}
+}
+
+func impersonationTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IMPERSONATION_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultImpersonationTokenTTL
+	}
+	return ttl
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/mailer"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+const defaultInvitationTokenTTL = 72 * time.Hour
+
+type invitationService struct {
+	userSvc  UserService
+	roleSvc  RoleService
+	auditSvc AuditEventService
+	mailer   mailer.Mailer
+	db       *gorm.DB
+	// organizationID limits the invitations to the users of the organization.
+	organizationID uint
+}
+
+type InvitationService interface {
+	ForOrganization(organizationID uint) InvitationService
+	Invite(userDTO *dtos.UserDTO, actor dtos.Actor) (*models.User, *models.Invitation, *application_types.ApplicationError)
+	InviteMember(user *models.User, role string, owner bool, branchIDs []uint, actor dtos.Actor) (*models.Invitation, *application_types.ApplicationError)
+	FindPending() ([]*models.Invitation, *application_types.ApplicationError)
+	Resend(id uint, actorRole string) (*models.Invitation, *application_types.ApplicationError)
+	Revoke(id uint, actorRole string) *application_types.ApplicationError
+	Accept(acceptDTO dtos.AcceptInvitationDTO) *application_types.ApplicationError
+}
+
+func NewInvitationService() InvitationService {
+	return &invitationService{
+		userSvc:  NewUserService(),
+		roleSvc:  NewRoleService(),
+		auditSvc: NewAuditEventService(),
+		mailer:   mailer.New(),
+		db:       db.Get(),
+	}
+}
+
+// ForOrganization returns the service limited to the invitations into the
+// organization. The zero organization sees every invitation.
+func (svc *invitationService) ForOrganization(organizationID uint) InvitationService {
+	scoped := *svc
+	scoped.organizationID = organizationID
+	scoped.userSvc = svc.userSvc.ForOrganization(organizationID)
+	scoped.roleSvc = svc.roleSvc.ForOrganization(organizationID)
+	return &scoped
+}
+
+func (svc *invitationService) scoped() *gorm.DB {
+	return svc.db.Scopes(models.ScopeOrganization(svc.organizationID))
+}
+
+// Invite creates an inactive user and emails an invitation link. The user
+// becomes active once the invitation is accepted with a password.
+func (svc *invitationService) Invite(userDTO *dtos.UserDTO, actor dtos.Actor) (*models.User, *models.Invitation, *application_types.ApplicationError) {
+	logger.Info("Invite user service started")
+	userDTO.Status = models.UserStatusInactive
+
+	user, appErr := svc.userSvc.Create(userDTO, actor)
+	if appErr != nil {
+		logger.Danger("Invite user service stopped")
+		return nil, nil, appErr
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate invitation token. Message: " + err.Error())
+		return nil, nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User invitation failed", err)
+	}
+
+	now := time.Now()
+	invitation := &models.Invitation{
+		UserID:      user.ID,
+		InvitedByID: actor.UserID,
+		Email:       user.Email,
+		TokenHash:   tokenHash,
+		ExpiresAt:   now.Add(invitationTokenTTL()),
+		SentAt:      now,
+
+		OrganizationID: optionalID(svc.organizationID),
+		Role:           userDTO.Role,
+		BranchIDs:      []uint{},
+	}
+	if err := svc.db.Create(invitation).Error; err != nil {
+		logger.HighlightedDanger("Unable to store invitation. Gorm Message: " + err.Error())
+		return nil, nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User invitation failed", err)
+	}
+
+	// The invitation exists at this point; a failed email can be resent by an admin.
+	if appErr := svc.send(user, token); appErr != nil {
+		logger.Warning("Unable to send the invitation email. Message: " + appErr.GetErrorMessage())
+	}
+
+	logger.Success("Invite user service success")
+	return user, invitation, nil
+}
+
+// InviteMember emails a user with an account an invitation to join the
+// organization. The user becomes a member with the role, the ownership and the
+// branches only once the invitation is accepted.
+func (svc *invitationService) InviteMember(user *models.User, role string, owner bool, branchIDs []uint, actor dtos.Actor) (*models.Invitation, *application_types.ApplicationError) {
+	logger.Info("Invite member service started for the user id " + strconv.FormatUint(uint64(user.ID), 10))
+	if svc.organizationID == 0 {
+		logger.Warning("Invite member service stopped. Message: No organization")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Member invitation failed",
+			fmt.Errorf("Members are invited into an organization"))
+	}
+
+	organization := &models.Organization{}
+	if err := svc.db.First(organization, svc.organizationID).Error; err != nil {
+		logger.Danger("Unable to find the organization. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Member invitation failed", err)
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate invitation token. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Member invitation failed", err)
+	}
+
+	now := time.Now()
+	invitation := &models.Invitation{
+		UserID:      user.ID,
+		InvitedByID: actor.UserID,
+		Email:       user.Email,
+		TokenHash:   tokenHash,
+		ExpiresAt:   now.Add(invitationTokenTTL()),
+		SentAt:      now,
+
+		OrganizationID: &organization.ID,
+		Role:           role,
+		Owner:          owner,
+		BranchIDs:      branchIDs,
+		Membership:     true,
+	}
+	err = svc.db.Transaction(func(tx *gorm.DB) error {
+		// A new invitation replaces the pending one of the user into the organization.
+		err := tx.Model(&models.Invitation{}).Where("user_id = ? AND organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL",
+			user.ID, organization.ID).Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(invitation).Error
+	})
+	if err != nil {
+		logger.HighlightedDanger("Unable to store invitation. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Member invitation failed", err)
+	}
+
+	if appErr := svc.sendMembership(user, organization, token); appErr != nil {
+		logger.Warning("Unable to send the invitation email. Message: " + appErr.GetErrorMessage())
+	}
+
+	event := newAuditEvent(models.AuditActionMemberInvited, models.AuditOutcomeSuccess, actor)
+	event.OrganizationID = &organization.ID
+	event.TargetType, event.TargetID = models.AuditTargetUser, &user.ID
+	event.Changes = map[string]models.AuditChange{
+		"role":       {From: nil, To: role},
+		"owner":      {From: nil, To: owner},
+		"branch_ids": {From: nil, To: branchIDs},
+	}
+	svc.auditSvc.Record(event)
+
+	logger.Success("Invite member service success")
+	return invitation, nil
+}
+
+func (svc *invitationService) FindPending() ([]*models.Invitation, *application_types.ApplicationError) {
+	logger.Info("Finding pending invitations")
+	var invitations []*models.Invitation
+	if err := svc.scoped().Where("accepted_at IS NULL AND revoked_at IS NULL").Order("created_at DESC").Find(&invitations).Error; err != nil {
+		logger.Danger("Unable to find invitations. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation find failed!", err)
+	}
+
+	logger.Success("Pending invitations found successfully")
+	return invitations, nil
+}
+
+// Resend replaces the token of a pending invitation, so that earlier links stop
+// working, and emails the new link with a fresh expiry.
+func (svc *invitationService) Resend(id uint, actorRole string) (*models.Invitation, *application_types.ApplicationError) {
+	logger.Info("Resend invitation service started for the id " + strconv.FormatUint(uint64(id), 10))
+	invitation, user, appErr := svc.findManageablePending(id, actorRole)
+	if appErr != nil {
+		logger.Danger("Resend invitation service stopped")
+		return nil, appErr
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate invitation token. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation resend failed", err)
+	}
+
+	now := time.Now()
+	invitation.TokenHash = tokenHash
+	invitation.ExpiresAt = now.Add(invitationTokenTTL())
+	invitation.SentAt = now
+	if err := svc.db.Save(invitation).Error; err != nil {
+		logger.HighlightedDanger("Unable to update invitation. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation resend failed", err)
+	}
+
+	if invitation.GrantsMembership() {
+		organization := &models.Organization{}
+		if err := svc.db.First(organization, *invitation.OrganizationID).Error; err != nil {
+			logger.Danger("Unable to find the organization. Gorm Message: " + err.Error())
+			return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation resend failed", err)
+		}
+		appErr = svc.sendMembership(user, organization, token)
+	} else {
+		appErr = svc.send(user, token)
+	}
+	if appErr != nil {
+		logger.Danger("Resend invitation service stopped")
+		return nil, appErr
+	}
+
+	logger.Success("Resend invitation service success")
+	return invitation, nil
+}
+
+// Revoke invalidates the link of a pending invitation. The invited user stays
+// inactive and can be deleted or invited again by an admin.
+func (svc *invitationService) Revoke(id uint, actorRole string) *application_types.ApplicationError {
+	logger.Info("Revoke invitation service started for the id " + strconv.FormatUint(uint64(id), 10))
+	invitation, _, appErr := svc.findManageablePending(id, actorRole)
+	if appErr != nil {
+		logger.Danger("Revoke invitation service stopped")
+		return appErr
+	}
+
+	if err := svc.db.Model(invitation).Update("revoked_at", time.Now()).Error; err != nil {
+		logger.HighlightedDanger("Unable to revoke invitation. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation revoke failed", err)
+	}
+
+	logger.Success("Revoke invitation service success")
+	return nil
+}
+
+// Accept sets the password of the invited user and activates the account. The
+// invitation is only consumed when the password meets the password policy.
+// Invitations of users with an account make them a member instead, without a
+// password.
+func (svc *invitationService) Accept(acceptDTO dtos.AcceptInvitationDTO) *application_types.ApplicationError {
+	logger.Info("Accept invitation service started")
+	if acceptDTO.Password != acceptDTO.ConfirmPassword {
+		logger.Danger("Confirm password and password are not identical.")
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invitation accept failed",
+			fmt.Errorf("Password and Confirm passwords are not identical")).AddFieldErrors("confirm_password", "must match the password")
+	}
+
+	var invitation models.Invitation
+	if err := svc.db.Where("token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL", auth.HashOpaqueToken(acceptDTO.Token)).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("Invalid, revoked or already accepted invitation token")
+			return application_types.NewApplicationError(false, http.StatusUnauthorized, "Invitation accept failed", fmt.Errorf("Invalid or already used invitation"))
+		}
+		logger.HighlightedDanger("Unable to find invitation. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation accept failed", err)
+	}
+
+	if invitation.ExpiresAt.Before(time.Now()) {
+		logger.Warning("Expired invitation token.")
+		return application_types.NewApplicationError(false, http.StatusUnauthorized, "Invitation accept failed", fmt.Errorf("Your invitation is expired. Please ask an admin to resend it"))
+	}
+
+	if invitation.GrantsMembership() {
+		if _, appErr := svc.roleSvc.ForOrganization(*invitation.OrganizationID).FindByName(invitation.Role); appErr != nil {
+			logger.Danger("Accept invitation service stopped. Message: " + appErr.GetErrorMessage())
+			return appErr
+		}
+	}
+
+	errInvitationConsumed := errors.New("Invalid or already used invitation")
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// Consumed first so a concurrent request cannot accept it twice.
+		res := tx.Model(&models.Invitation{}).Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).Update("accepted_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvitationConsumed
+		}
+
+		if invitation.GrantsMembership() {
+			return acceptMembership(tx, &invitation)
+		}
+
+		if _, err := auth.NewPasswordWithTx(tx, invitation.UserID, acceptDTO.Password); err != nil {
+			return err
+		}
+
+		// Following the emailed link proves the ownership of the address.
+		return tx.Model(&models.User{}).Where("id = ?", invitation.UserID).
+			Updates(map[string]interface{}{"status": models.UserStatusActive, "email_verified_at": now}).Error
+	})
+	if errors.Is(err, errInvitationConsumed) {
+		logger.Warning("Invitation consumed by another request")
+		return application_types.NewApplicationError(false, http.StatusUnauthorized, "Invitation accept failed", err)
+	}
+	if errors.Is(err, errInvitationBranchesGone) {
+		logger.Warning("Accept invitation service stopped. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invitation accept failed", err)
+	}
+	if err != nil {
+		logger.Danger("Accept invitation service stopped. Message: " + err.Error())
+		return newPasswordError(err, "password", "Invitation accept failed")
+	}
+
+	if invitation.GrantsMembership() {
+		event := newAuditEvent(models.AuditActionMemberAdded, models.AuditOutcomeSuccess, dtos.Actor{UserID: invitation.UserID})
+		event.OrganizationID = invitation.OrganizationID
+		event.TargetType, event.TargetID = models.AuditTargetUser, &invitation.UserID
+		event.Details = "Accepted the invitation id " + strconv.FormatUint(uint64(invitation.ID), 10)
+		event.Changes = map[string]models.AuditChange{
+			"role":       {From: nil, To: invitation.Role},
+			"owner":      {From: nil, To: invitation.Owner},
+			"branch_ids": {From: nil, To: invitation.BranchIDs},
+		}
+		svc.auditSvc.Record(event)
+	}
+
+	forgetUserStatus(invitation.UserID)
+	logger.Success("Accept invitation service success for the user id " + strconv.FormatUint(uint64(invitation.UserID), 10))
+	return nil
+}
+
+var errInvitationBranchesGone = errors.New("A branch of the invitation no longer exists. Please ask an admin to invite you again")
+
+// acceptMembership makes the invited user a member as described by the
+// invitation, unless the user joined the organization in the meantime.
+func acceptMembership(tx *gorm.DB, invitation *models.Invitation) error {
+	var members int64
+	err := tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", *invitation.OrganizationID, invitation.UserID).
+		Count(&members).Error
+	if err != nil || members > 0 {
+		return err
+	}
+
+	member := &models.OrganizationMember{OrganizationID: *invitation.OrganizationID, UserID: invitation.UserID,
+		Role: invitation.Role, Owner: invitation.Owner}
+	if err := addOrganizationMember(tx, member); err != nil {
+		return err
+	}
+	if len(invitation.BranchIDs) == 0 {
+		return nil
+	}
+
+	// A member restricted to fewer branches must not end up in every branch.
+	var branches []*models.Branch
+	if err := tx.Where("organization_id = ? AND id IN ?", member.OrganizationID, invitation.BranchIDs).Find(&branches).Error; err != nil {
+		return err
+	}
+	if len(branches) != len(invitation.BranchIDs) {
+		return errInvitationBranchesGone
+	}
+	return tx.Model(member).Association("Branches").Replace(branches)
+}
+
+func (svc *invitationService) findManageablePending(id uint, actorRole string) (*models.Invitation, *models.User, *application_types.ApplicationError) {
+	var invitation models.Invitation
+	if err := svc.scoped().First(&invitation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, application_types.NewApplicationError(false, http.StatusNotFound, "No invitation found for the given id", err)
+		}
+		logger.HighlightedDanger("Unable to find invitation. Gorm Message: " + err.Error())
+		return nil, nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find invitation", err)
+	}
+
+	if !invitation.IsPending() {
+		return nil, nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invitation is not pending",
+			fmt.Errorf("The invitation is already accepted or revoked"))
+	}
+
+	// Users invited to join are not members of the organization yet.
+	userSvc := svc.userSvc
+	if invitation.GrantsMembership() {
+		userSvc = svc.userSvc.ForOrganization(0)
+	}
+	user, appErr := userSvc.FindByID(invitation.UserID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	role := user.Role
+	if invitation.GrantsMembership() {
+		role = invitation.Role
+	}
+
+	canManage, appErr := svc.roleSvc.CanManageRole(actorRole, role)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	if !canManage {
+		logger.Warning("Access denied for the role '" + actorRole + "' to manage an invitation for the role '" + role + "'")
+		return nil, nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("Only superadmins can manage %s accounts", role))
+	}
+
+	return &invitation, user, nil
+}
+
+func (svc *invitationService) send(user *models.User, token string) *application_types.ApplicationError {
+	link := os.Getenv("APP_BASE_URL") + "/accept-invitation?token=" + token
+	body := "Hi " + user.Name + ",\n\n" +
+		"You have been invited to Treeforms Billing. Use the link below to choose your password and activate your account:\n\n" +
+		link + "\n\n" +
+		"The link expires in " + invitationTokenTTL().String() + " and can be used only once.\n"
+
+	if err := svc.mailer.Send(user.Email, "You are invited to Treeforms Billing", body); err != nil {
+		logger.HighlightedDanger("Unable to send invitation email. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation email failed", err)
+	}
+	return nil
+}
+
+func (svc *invitationService) sendMembership(user *models.User, organization *models.Organization, token string) *application_types.ApplicationError {
+	link := os.Getenv("APP_BASE_URL") + "/accept-invitation?token=" + token
+	body := "Hi " + user.Name + ",\n\n" +
+		"You have been invited to join " + organization.Name + " on Treeforms Billing. Use the link below to accept the invitation:\n\n" +
+		link + "\n\n" +
+		"The link expires in " + invitationTokenTTL().String() + " and can be used only once. Ignore this email if you do not want to join.\n"
+
+	if err := svc.mailer.Send(user.Email, "You are invited to join "+organization.Name, body); err != nil {
+		logger.HighlightedDanger("Unable to send invitation email. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Invitation email failed", err)
+	}
+	return nil
+}
+
+func invitationTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("INVITATION_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultInvitationTokenTTL
+	}
+	return ttl
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"treeforms_billing/db"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginFailuresKeyPrefix = "login_failures:"
+	loginLockKeyPrefix     = "login_lock:"
+)
+
+// loginAttemptStore keeps failed login attempts in sliding windows and the
+// resulting locks. Keys have the form "<subject>:<value>", e.g. "email:a@b.com".
+type loginAttemptStore interface {
+	AddFailure(key string, at time.Time, window time.Duration) (int, error)
+	ClearFailures(key string) error
+	SetLock(key string, until time.Time) error
+	GetLock(key string) (time.Time, bool, error)
+	ListLocks() (map[string]time.Time, error)
+	DeleteLock(key string) error
+}
+
+var memoryLoginAttempts = &memoryLoginAttemptStore{
+	failures: map[string][]time.Time{},
+	locks:    map[string]time.Time{},
+}
+
+func newLoginAttemptStore() loginAttemptStore {
+	if db.IsRedisConfigured() {
+		return &redisLoginAttemptStore{}
+	}
+	return memoryLoginAttempts
+}
+
+type redisLoginAttemptStore struct{}
+
+func (s *redisLoginAttemptStore) AddFailure(key string, at time.Time, window time.Duration) (int, error) {
+	ctx := context.Background()
+	redisKey := loginFailuresKeyPrefix + key
+	member := strconv.FormatInt(at.UnixNano(), 10)
+
+	pipe := db.GetRedis().TxPipeline()
+	pipe.ZRemRangeByScore(ctx, redisKey, "0", strconv.FormatInt(at.Add(-window).UnixNano(), 10))
+	pipe.ZAdd(ctx, redisKey, redis.Z{Score: float64(at.UnixNano()), Member: member})
+	count := pipe.ZCard(ctx, redisKey)
+	pipe.Expire(ctx, redisKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return int(count.Val()), nil
+}
+
+func (s *redisLoginAttemptStore) ClearFailures(key string) error {
+	return db.DeleteFromRedisCache(loginFailuresKeyPrefix + key)
+}
+
+func (s *redisLoginAttemptStore) SetLock(key string, until time.Time) error {
+	return db.SetRedisCache(loginLockKeyPrefix+key, until.Unix(), time.Until(until))
+}
+
+func (s *redisLoginAttemptStore) GetLock(key string) (time.Time, bool, error) {
+	value, err := db.GetFromRedisCache(loginLockKeyPrefix + key)
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, false, nil
+	} else if err != nil {
+		return time.Time{}, false, err
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(unix, 0), true, nil
+}
+
+func (s *redisLoginAttemptStore) ListLocks() (map[string]time.Time, error) {
+	ctx := context.Background()
+	locks := map[string]time.Time{}
+
+	iter := db.GetRedis().Scan(ctx, 0, loginLockKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := strings.TrimPrefix(iter.Val(), loginLockKeyPrefix)
+		until, ok, err := s.GetLock(key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			locks[key] = until
+		}
+	}
+
+	return locks, iter.Err()
+}
+
+func (s *redisLoginAttemptStore) DeleteLock(key string) error {
+	return db.DeleteFromRedisCache(loginLockKeyPrefix + key)
+}
+
+// memoryLoginAttemptStore is used when Redis is not configured. Its state is
+// local to the process, so it only suits single instance deployments.
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	locks    map[string]time.Time
+}
+
+func (s *memoryLoginAttemptStore) AddFailure(key string, at time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := at.Add(-window)
+	recent := s.failures[key][:0]
+	for _, failedAt := range s.failures[key] {
+		if failedAt.After(cutoff) {
+			recent = append(recent, failedAt)
+		}
+	}
+	s.failures[key] = append(recent, at)
+
+	return len(s.failures[key]), nil
+}
+
+func (s *memoryLoginAttemptStore) ClearFailures(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) SetLock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locks[key] = until
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) GetLock(key string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.locks[key]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	if !until.After(time.Now()) {
+		delete(s.locks, key)
+		return time.Time{}, false, nil
+	}
+	return until, true, nil
+}
+
+func (s *memoryLoginAttemptStore) ListLocks() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks := map[string]time.Time{}
+	for key, until := range s.locks {
+		if until.After(time.Now()) {
+			locks[key] = until
+		} else {
+			delete(s.locks, key)
+		}
+	}
+	return locks, nil
+}
+
+func (s *memoryLoginAttemptStore) DeleteLock(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.locks, key)
+	return nil
+}
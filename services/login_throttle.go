@@ -0,0 +1,163 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+)
+
+const (
+	loginFailureWindow = 15 * time.Minute
+	loginLockoutPeriod = 15 * time.Minute
+	// Failures of an account before every further failure delays the next try.
+	loginDelayAfterFailures = 3
+	loginMaxDelay           = time.Minute
+	// Failures within the window after which the account or IP is locked out.
+	loginMaxFailuresPerAccount = 10
+	loginMaxFailuresPerIP      = 50
+)
+
+type loginThrottleService struct {
+	store loginAttemptStore
+}
+
+// LoginThrottleService throttles failed logins of an account, identified by a
+// lockout subject such as models.LockoutSubjectEmail and its value, and of the
+// client IP across every account.
+type LoginThrottleService interface {
+	Check(subject, value, ip string) *application_types.ApplicationError
+	RecordFailure(subject, value, ip string)
+	RecordSuccess(subject, value string)
+	FindLockouts() ([]*models.LoginLockout, *application_types.ApplicationError)
+	ClearLockout(subject, value string) *application_types.ApplicationError
+}
+
+func NewLoginThrottleService() LoginThrottleService {
+	return &loginThrottleService{
+		store: newLoginAttemptStore(),
+	}
+}
+
+// Check returns a 429 error with a Retry-After header while the account or the
+// client IP is delayed or locked out.
+func (svc *loginThrottleService) Check(subject, value, ip string) *application_types.ApplicationError {
+	for _, key := range []string{lockoutKey(subject, value), lockoutKey(models.LockoutSubjectIP, ip)} {
+		until, locked, err := svc.store.GetLock(key)
+		if err != nil {
+			// Failing open keeps logins possible when the store is down.
+			logger.HighlightedDanger("Unable to check login lockout. Message: " + err.Error())
+			continue
+		}
+		if !locked {
+			continue
+		}
+
+		retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		logger.Warning("Login blocked for " + key + " for " + strconv.Itoa(retryAfter) + " seconds")
+		return application_types.NewApplicationError(false, http.StatusTooManyRequests, "Too many failed login attempts",
+			fmt.Errorf("Too many failed login attempts. Try again in %d seconds", retryAfter)).
+			SetHeader("Retry-After", strconv.Itoa(retryAfter))
+	}
+
+	return nil
+}
+
+func (svc *loginThrottleService) RecordFailure(subject, value, ip string) {
+	now := time.Now()
+
+	accountKey := lockoutKey(subject, value)
+	failures, err := svc.store.AddFailure(accountKey, now, loginFailureWindow)
+	if err != nil {
+		logger.HighlightedDanger("Unable to record failed login. Message: " + err.Error())
+	} else if failures >= loginMaxFailuresPerAccount {
+		svc.lock(accountKey, now.Add(loginLockoutPeriod))
+	} else if failures >= loginDelayAfterFailures {
+		// 1s, 2s, 4s, ... capped at loginMaxDelay.
+		delay := time.Duration(1<<(failures-loginDelayAfterFailures)) * time.Second
+		if delay > loginMaxDelay {
+			delay = loginMaxDelay
+		}
+		svc.lock(accountKey, now.Add(delay))
+	}
+
+	ipKey := lockoutKey(models.LockoutSubjectIP, ip)
+	failures, err = svc.store.AddFailure(ipKey, now, loginFailureWindow)
+	if err != nil {
+		logger.HighlightedDanger("Unable to record failed login. Message: " + err.Error())
+	} else if failures >= loginMaxFailuresPerIP {
+		svc.lock(ipKey, now.Add(loginLockoutPeriod))
+	}
+}
+
+func (svc *loginThrottleService) RecordSuccess(subject, value string) {
+	if err := svc.store.ClearFailures(lockoutKey(subject, value)); err != nil {
+		logger.Warning("Unable to clear failed logins. Message: " + err.Error())
+	}
+}
+
+func (svc *loginThrottleService) FindLockouts() ([]*models.LoginLockout, *application_types.ApplicationError) {
+	logger.Info("Finding login lockouts")
+	locks, err := svc.store.ListLocks()
+	if err != nil {
+		logger.Danger("Unable to find login lockouts. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Login lockout find failed!", err)
+	}
+
+	lockouts := make([]*models.LoginLockout, 0, len(locks))
+	for key, until := range locks {
+		subject, value, _ := strings.Cut(key, ":")
+		lockouts = append(lockouts, &models.LoginLockout{Subject: subject, Value: value, LockedUntil: until})
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].LockedUntil.After(lockouts[j].LockedUntil) })
+
+	logger.Success("Login lockouts found successfully")
+	return lockouts, nil
+}
+
+func (svc *loginThrottleService) ClearLockout(subject, value string) *application_types.ApplicationError {
+	if !slices.Contains([]string{models.LockoutSubjectEmail, models.LockoutSubjectPhone, models.LockoutSubjectUser, models.LockoutSubjectIP}, subject) {
+		return application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request", fmt.Errorf("Subject must be email, phone, user or ip"))
+	}
+
+	key := lockoutKey(subject, value)
+	logger.Info("Clearing login lockout for " + key)
+	if err := svc.store.DeleteLock(key); err != nil {
+		logger.Danger("Unable to clear login lockout. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Login lockout clear failed", err)
+	}
+	if err := svc.store.ClearFailures(key); err != nil {
+		logger.Danger("Unable to clear failed logins. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Login lockout clear failed", err)
+	}
+
+	logger.Success("Cleared login lockout for " + key)
+	return nil
+}
+
+func (svc *loginThrottleService) lock(key string, until time.Time) {
+	logger.Warning("Locking logins for " + key + " until " + until.Format(time.RFC3339))
+	if err := svc.store.SetLock(key, until); err != nil {
+		logger.HighlightedDanger("Unable to lock logins. Message: " + err.Error())
+	}
+}
+
+func lockoutKey(subject, value string) string {
+	switch subject {
+	case models.LockoutSubjectEmail:
+		value = strings.ToLower(strings.TrimSpace(value))
+	case models.LockoutSubjectPhone:
+		value = strings.TrimSpace(value)
+	}
+	return subject + ":" + value
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	mfaChallengeKeyPrefix   = "mfa_challenge:"
+	mfaAttemptsKeyPrefix    = "mfa_challenge_attempts:"
+)
+
+type mfaService struct {
+	userSvc     UserService
+	throttleSvc LoginThrottleService
+}
+
+type MFAService interface {
+	Enroll(userID uint) (secret, uri string, appErr *application_types.ApplicationError)
+	Confirm(userID uint, code string) (recoveryCodes []string, appErr *application_types.ApplicationError)
+	IsEnabled(userID uint) (bool, *application_types.ApplicationError)
+	NewChallenge(userID uint) (string, *application_types.ApplicationError)
+	VerifyChallenge(mfaToken, code, ip string) (uint, *application_types.ApplicationError)
+	Reset(userID uint) *application_types.ApplicationError
+}
+
+func NewMFAService() MFAService {
+	return &mfaService{
+		userSvc:     NewUserService(),
+		throttleSvc: NewLoginThrottleService(),
+	}
+}
+
+func (svc *mfaService) Enroll(userID uint) (secret, uri string, appErr *application_types.ApplicationError) {
+	logger.Info("MFA enroll service started")
+	user, appErr := svc.userSvc.FindByID(userID)
+	if appErr != nil {
+		logger.Danger("MFA enroll service stopped")
+		return "", "", appErr
+	}
+
+	existing, err := auth.GetMFASecretByUserID(userID)
+	if err != nil {
+		logger.Danger("MFA enroll service stopped")
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA enrollment failed", err)
+	}
+	if existing != nil && existing.IsConfirmed() {
+		logger.Warning("MFA enroll service stopped. Message: MFA is already enabled.")
+		return "", "", application_types.NewApplicationError(false, http.StatusConflict, "MFA enrollment failed", fmt.Errorf("MFA is already enabled for this account"))
+	}
+
+	mfaSecret, err := auth.NewMFASecret(userID)
+	if err != nil {
+		logger.Danger("MFA enroll service stopped")
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA enrollment failed", err)
+	}
+
+	logger.Success("MFA enroll service success")
+	return mfaSecret.GetSecret(), auth.TOTPProvisioningURI(mfaIssuer(), user.Email, mfaSecret.GetSecret()), nil
+}
+
+func (svc *mfaService) Confirm(userID uint, code string) (recoveryCodes []string, appErr *application_types.ApplicationError) {
+	logger.Info("MFA confirm service started")
+	mfaSecret, err := auth.GetMFASecretByUserID(userID)
+	if err != nil {
+		logger.Danger("MFA confirm service stopped")
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA confirmation failed", err)
+	}
+	if mfaSecret == nil {
+		logger.Warning("MFA confirm service stopped. Message: MFA enrollment not started.")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "MFA confirmation failed", fmt.Errorf("Start the MFA enrollment before confirming it"))
+	}
+	if mfaSecret.IsConfirmed() {
+		logger.Warning("MFA confirm service stopped. Message: MFA is already enabled.")
+		return nil, application_types.NewApplicationError(false, http.StatusConflict, "MFA confirmation failed", fmt.Errorf("MFA is already enabled for this account"))
+	}
+
+	if !mfaSecret.VerifyCode(code) {
+		logger.Warning("MFA confirm service stopped. Message: Invalid code.")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "MFA confirmation failed", fmt.Errorf("Invalid authentication code"))
+	}
+
+	recoveryCodes, err = auth.NewRecoveryCodes(userID)
+	if err != nil {
+		logger.Danger("MFA confirm service stopped")
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA confirmation failed", err)
+	}
+
+	if err := mfaSecret.Confirm(); err != nil {
+		logger.Danger("MFA confirm service stopped")
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA confirmation failed", err)
+	}
+
+	logger.Success("MFA confirm service success")
+	return recoveryCodes, nil
+}
+
+func (svc *mfaService) IsEnabled(userID uint) (bool, *application_types.ApplicationError) {
+	mfaSecret, err := auth.GetMFASecretByUserID(userID)
+	if err != nil {
+		return false, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to check MFA status", err)
+	}
+
+	return mfaSecret != nil && mfaSecret.IsConfirmed(), nil
+}
+
+// NewChallenge stores a short lived "mfa pending" challenge for the user whose
+// password is already verified and returns the opaque token identifying it.
+func (svc *mfaService) NewChallenge(userID uint) (string, *application_types.ApplicationError) {
+	logger.Info("New MFA challenge service started")
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate mfa challenge. Message: " + err.Error())
+		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA challenge creation failed", err)
+	}
+
+	if err := db.SetRedisCache(mfaChallengeKeyPrefix+tokenHash, userID, mfaChallengeTTL); err != nil {
+		logger.HighlightedDanger("Unable to store mfa challenge. Message: " + err.Error())
+		return "", application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA challenge creation failed", err)
+	}
+
+	logger.Success("New MFA challenge service success")
+	return token, nil
+}
+
+// VerifyChallenge accepts either a TOTP code or a recovery code and returns the
+// user id of the challenge. The challenge is consumed on success. Invalid codes
+// are also throttled per user, since a new challenge only takes the password.
+func (svc *mfaService) VerifyChallenge(mfaToken, code, ip string) (uint, *application_types.ApplicationError) {
+	logger.Info("Verify MFA challenge service started")
+	tokenHash := auth.HashOpaqueToken(mfaToken)
+	challengeKey := mfaChallengeKeyPrefix + tokenHash
+	attemptsKey := mfaAttemptsKeyPrefix + tokenHash
+
+	userIDStr, err := db.GetFromRedisCache(challengeKey)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			logger.Warning("Invalid or expired mfa challenge")
+			return 0, application_types.NewApplicationError(false, http.StatusUnauthorized, "MFA verification failed", fmt.Errorf("Invalid or expired MFA challenge. Please login again"))
+		}
+		logger.HighlightedDanger("Unable to read mfa challenge. Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA verification failed", err)
+	}
+
+	userID, err := strconv.ParseUint(userIDStr, 10, 0)
+	if err != nil {
+		logger.HighlightedDanger("Corrupted mfa challenge. Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA verification failed", err)
+	}
+
+	if appErr := svc.throttleSvc.Check(models.LockoutSubjectUser, userIDStr, ip); appErr != nil {
+		logger.Warning("Verify MFA challenge service stopped. Message: MFA codes of the user are throttled")
+		return 0, appErr
+	}
+
+	ctx := context.Background()
+	attempts, err := db.GetRedis().Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		logger.HighlightedDanger("Unable to count mfa attempts. Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA verification failed", err)
+	}
+	if attempts == 1 {
+		db.GetRedis().Expire(ctx, attemptsKey, mfaChallengeTTL)
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		logger.Warning("Too many mfa attempts. Dropping the challenge.")
+		db.DeleteFromRedisCache(challengeKey, attemptsKey)
+		return 0, application_types.NewApplicationError(false, http.StatusTooManyRequests, "MFA verification failed", fmt.Errorf("Too many invalid codes. Please login again"))
+	}
+
+	mfaSecret, err := auth.GetMFASecretByUserID(uint(userID))
+	if err != nil || mfaSecret == nil {
+		logger.Danger("Verify MFA challenge service stopped")
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA verification failed", fmt.Errorf("Unable to load MFA secret of the user"))
+	}
+
+	if !mfaSecret.VerifyCode(code) {
+		used, err := auth.UseRecoveryCode(uint(userID), code)
+		if err != nil {
+			logger.Danger("Verify MFA challenge service stopped")
+			return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA verification failed", err)
+		}
+		if !used {
+			logger.Warning("Invalid mfa code")
+			svc.throttleSvc.RecordFailure(models.LockoutSubjectUser, userIDStr, ip)
+			return 0, application_types.NewApplicationError(false, http.StatusUnauthorized, "MFA verification failed", fmt.Errorf("Invalid authentication code"))
+		}
+		logger.Warning("Recovery code used for the user id " + userIDStr)
+	}
+
+	if err := db.DeleteFromRedisCache(challengeKey, attemptsKey); err != nil {
+		logger.Warning("Unable to delete mfa challenge. Message: " + err.Error())
+	}
+	svc.throttleSvc.RecordSuccess(models.LockoutSubjectUser, userIDStr)
+
+	logger.Success("Verify MFA challenge service success")
+	return uint(userID), nil
+}
+
+func (svc *mfaService) Reset(userID uint) *application_types.ApplicationError {
+	logger.Info("Reset MFA service started for the user id " + strconv.FormatUint(uint64(userID), 10))
+	if _, appErr := svc.userSvc.FindByID(userID); appErr != nil {
+		logger.Danger("Reset MFA service stopped")
+		return appErr
+	}
+
+	if err := auth.DeleteMFAByUserID(userID); err != nil {
+		logger.HighlightedDanger("Unable to reset mfa. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "MFA reset failed", err)
+	}
+
+	logger.Success("Reset MFA service success")
+	return nil
+}
+
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Treeforms Billing"
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+	"treeforms_billing/sso"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	OIDCStateTTL       = 10 * time.Minute
+	oidcStateKeyPrefix = "oidc_state:"
+	oidcRequestTimeout = 15 * time.Second
+)
+
+// oidcLoginState is kept in Redis between the redirect to the provider and the callback.
+type oidcLoginState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type oidcIDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+type oidcService struct {
+	userSvc UserService
+	db      *gorm.DB
+}
+
+type OIDCService interface {
+	AuthorizationURL(providerName string) (url, state string, appErr *application_types.ApplicationError)
+	ResolveUser(providerName, code, state string) (*models.User, *application_types.ApplicationError)
+}
+
+func NewOIDCService() OIDCService {
+	return &oidcService{
+		userSvc: NewUserService(),
+		db:      db.Get(),
+	}
+}
+
+// AuthorizationURL starts an authorization code flow with PKCE. The state, nonce
+// and code verifier are stored in Redis and consumed once by ResolveUser. The
+// state is returned as well, so that it can be bound to the browser.
+func (svc *oidcService) AuthorizationURL(providerName string) (url, state string, appErr *application_types.ApplicationError) {
+	logger.Info("OIDC authorization URL service started")
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	provider, err := sso.GetProvider(ctx, providerName)
+	if err != nil {
+		logger.Danger("OIDC authorization URL service stopped. Message: " + err.Error())
+		return "", "", application_types.NewApplicationError(false, http.StatusNotFound, "SSO login failed", err)
+	}
+
+	state, err = auth.NewRandomID()
+	if err != nil {
+		logger.HighlightedDanger("OIDC authorization URL service stopped. Message: " + err.Error())
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+	nonce, err := auth.NewRandomID()
+	if err != nil {
+		logger.HighlightedDanger("OIDC authorization URL service stopped. Message: " + err.Error())
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+
+	loginState := oidcLoginState{
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+	}
+	payload, err := json.Marshal(loginState)
+	if err != nil {
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+
+	if err := db.SetRedisCache(oidcStateKeyPrefix+state, string(payload), OIDCStateTTL); err != nil {
+		logger.HighlightedDanger("Unable to store the OIDC login state. Message: " + err.Error())
+		return "", "", application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+
+	url = provider.OAuth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(loginState.CodeVerifier))
+
+	logger.Success("OIDC authorization URL service success")
+	return url, state, nil
+}
+
+// ResolveUser completes the authorization code flow and returns the local user
+// of the identity. Unknown identities are linked to the user with the same
+// verified email, or provisioned as a new user when the provider allows it.
+func (svc *oidcService) ResolveUser(providerName, code, state string) (*models.User, *application_types.ApplicationError) {
+	logger.Info("OIDC resolve user service started")
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	provider, err := sso.GetProvider(ctx, providerName)
+	if err != nil {
+		logger.Danger("OIDC resolve user service stopped. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusNotFound, "SSO login failed", err)
+	}
+
+	loginState, appErr := svc.consumeState(ctx, state)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if loginState.Provider != provider.Name {
+		logger.Warning("OIDC resolve user service stopped. Message: State issued for another provider")
+		return nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("Invalid or expired login state"))
+	}
+
+	oauthToken, err := provider.OAuth2.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		logger.Warning("OIDC code exchange failed. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("Unable to exchange the authorization code"))
+	}
+
+	rawIDToken, ok := oauthToken.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		logger.Warning("OIDC resolve user service stopped. Message: No id_token in the token response")
+		return nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("The provider did not return an ID token"))
+	}
+
+	idToken, err := provider.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		logger.Warning("OIDC ID token verification failed. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("Invalid ID token"))
+	}
+	if idToken.Nonce != loginState.Nonce {
+		logger.Warning("OIDC resolve user service stopped. Message: Nonce mismatch")
+		return nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("Invalid ID token"))
+	}
+
+	var claims oidcIDTokenClaims
+	if err := idToken.Claims(&claims); err != nil {
+		logger.Warning("Unable to parse the OIDC ID token claims. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("Invalid ID token"))
+	}
+	claims.Email = strings.ToLower(strings.TrimSpace(claims.Email))
+
+	user, appErr := svc.findLinkedUser(provider.Name, idToken.Subject)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if user == nil {
+		user, appErr = svc.linkOrProvision(provider, idToken.Subject, claims)
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	logger.Success("OIDC resolve user service success")
+	return user, nil
+}
+
+func (svc *oidcService) consumeState(ctx context.Context, state string) (*oidcLoginState, *application_types.ApplicationError) {
+	invalidState := application_types.NewApplicationError(false, http.StatusUnauthorized, "SSO login failed", fmt.Errorf("Invalid or expired login state"))
+	if state == "" {
+		return nil, invalidState
+	}
+
+	payload, err := db.GetRedis().GetDel(ctx, oidcStateKeyPrefix+state).Result()
+	if errors.Is(err, redis.Nil) {
+		logger.Warning("OIDC resolve user service stopped. Message: Unknown state")
+		return nil, invalidState
+	}
+	if err != nil {
+		logger.HighlightedDanger("Unable to read the OIDC login state. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+
+	var loginState oidcLoginState
+	if err := json.Unmarshal([]byte(payload), &loginState); err != nil {
+		logger.HighlightedDanger("Unable to decode the OIDC login state. Message: " + err.Error())
+		return nil, invalidState
+	}
+
+	return &loginState, nil
+}
+
+func (svc *oidcService) findLinkedUser(providerName, subject string) (*models.User, *application_types.ApplicationError) {
+	var identity models.ExternalIdentity
+	err := svc.db.Where("provider = ? AND subject = ?", providerName, subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.HighlightedDanger("Unable to find the external identity. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+
+	return svc.userSvc.FindByID(identity.UserID)
+}
+
+func (svc *oidcService) linkOrProvision(provider *sso.Provider, subject string, claims oidcIDTokenClaims) (*models.User, *application_types.ApplicationError) {
+	if claims.Email == "" || !claims.EmailVerified {
+		logger.Warning("OIDC login stopped. Message: Email missing or not verified by the provider")
+		return nil, application_types.NewApplicationError(false, http.StatusForbidden, "SSO login failed", fmt.Errorf("The provider did not return a verified email address"))
+	}
+	if !provider.IsEmailAllowed(claims.Email) {
+		logger.Warning("OIDC login stopped. Message: Email domain not allowed for the provider " + provider.Name)
+		return nil, application_types.NewApplicationError(false, http.StatusForbidden, "SSO login failed", fmt.Errorf("The email domain is not allowed to login with %s", provider.Name))
+	}
+
+	user, appErr := svc.userSvc.FindByEmail(claims.Email)
+	if appErr != nil && !errors.Is(appErr.GetError(), gorm.ErrRecordNotFound) {
+		return nil, appErr
+	}
+	if user == nil && !provider.AutoProvision {
+		logger.Warning("OIDC login stopped. Message: No user for the email and provisioning is disabled")
+		return nil, application_types.NewApplicationError(false, http.StatusForbidden, "SSO login failed", fmt.Errorf("No account exists for %s", claims.Email))
+	}
+
+	now := time.Now()
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if user == nil {
+			name := strings.TrimSpace(claims.Name)
+			if name == "" {
+				name, _, _ = strings.Cut(claims.Email, "@")
+			}
+			user = &models.User{
+				Name:            name,
+				Email:           claims.Email,
+				Role:            models.RoleUser,
+				Status:          models.UserStatusActive,
+				EmailVerifiedAt: &now,
+			}
+			if provider.OrganizationID != 0 {
+				user.OrganizationID = &provider.OrganizationID
+			}
+			if err := tx.Create(user).Error; err != nil {
+				logger.HighlightedDanger("Unable to provision the SSO user. Gorm Message: " + err.Error())
+				return err
+			}
+			if provider.OrganizationID != 0 {
+				member := &models.OrganizationMember{OrganizationID: provider.OrganizationID, UserID: user.ID, Role: models.RoleUser}
+				if err := addOrganizationMember(tx, member); err != nil {
+					logger.HighlightedDanger("Unable to add the SSO user to the organization. Gorm Message: " + err.Error())
+					return err
+				}
+			}
+			logger.Info("Provisioned a user from the OIDC provider " + provider.Name)
+		} else if !user.IsEmailVerified() {
+			// The provider vouched for the address.
+			if err := tx.Model(user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		identity := &models.ExternalIdentity{
+			UserID:   user.ID,
+			Provider: provider.Name,
+			Subject:  subject,
+			Email:    claims.Email,
+		}
+		if err := tx.Create(identity).Error; err != nil {
+			logger.HighlightedDanger("Unable to link the external identity. Gorm Message: " + err.Error())
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "SSO login failed", err)
+	}
+
+	logger.Success("Linked the OIDC identity of " + provider.Name + " to the user")
+	return user, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+type organizationService struct {
+	db *gorm.DB
+}
+
+type OrganizationService interface {
+	Create(organizationDTO *dtos.OrganizationDTO) (*models.Organization, *application_types.ApplicationError)
+	Find() ([]*models.Organization, *application_types.ApplicationError)
+	FindByID(id uint) (*models.Organization, *application_types.ApplicationError)
+	UpdateByID(id uint, organizationDTO *dtos.OrganizationDTO) (*models.Organization, *application_types.ApplicationError)
+}
+
+func NewOrganizationService() OrganizationService {
+	return &organizationService{
+		db: db.Get(),
+	}
+}
+
+func (svc *organizationService) Create(organizationDTO *dtos.OrganizationDTO) (*models.Organization, *application_types.ApplicationError) {
+	logger.Info("Creating a new organization.")
+	organization := &models.Organization{Name: strings.TrimSpace(organizationDTO.Name)}
+
+	if err := organization.ValidateFields(); err != nil {
+		appErr := application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request",
+			fmt.Errorf("Validation failed for creating the organization. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	if err := svc.db.Create(organization).Error; err != nil {
+		logger.Danger("Organization creation failed. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization creation failed", err)
+	}
+
+	logger.Success("Organization created succesfully.")
+	return organization, nil
+}
+
+func (svc *organizationService) Find() ([]*models.Organization, *application_types.ApplicationError) {
+	logger.Info("Finding organizations")
+	var organizations []*models.Organization
+	if err := svc.db.Order("name").Find(&organizations).Error; err != nil {
+		logger.Danger("Unable to find organizations. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization find failed!", err)
+	}
+
+	logger.Success("Organizations found successfully")
+	return organizations, nil
+}
+
+func (svc *organizationService) FindByID(id uint) (*models.Organization, *application_types.ApplicationError) {
+	organization := &models.Organization{}
+	if err := svc.db.First(organization, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Danger("No organization found for the id " + strconv.FormatUint(uint64(id), 10))
+			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No organization found for the given id", err)
+		}
+		logger.Danger("Unable to find organization by id. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find organization with id", err)
+	}
+
+	return organization, nil
+}
+
+func (svc *organizationService) UpdateByID(id uint, organizationDTO *dtos.OrganizationDTO) (*models.Organization, *application_types.ApplicationError) {
+	logger.Info("Updating the organization id " + strconv.FormatUint(uint64(id), 10))
+	organization, appErr := svc.FindByID(id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if strings.TrimSpace(organizationDTO.Name) != "" {
+		organization.Name = strings.TrimSpace(organizationDTO.Name)
+	}
+
+	if err := organization.ValidateFields(); err != nil {
+		appErr = application_types.NewApplicationError(false, http.StatusBadRequest, "Organization update failed",
+			fmt.Errorf("Validation failed. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	if err := svc.db.Save(organization).Error; err != nil {
+		logger.Danger("Unable to update the organization. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization update failed.", err)
+	}
+
+	logger.Success("Organization updated by id " + strconv.FormatUint(uint64(id), 10))
+	return organization, nil
+}
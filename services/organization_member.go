@@ -0,0 +1,524 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+type organizationMemberService struct {
+	userSvc       UserService
+	invitationSvc InvitationService
+	roleSvc       RoleService
+	revocationSvc TokenRevocationService
+	auditSvc      AuditEventService
+	db            *gorm.DB
+}
+
+type OrganizationMemberService interface {
+	FindByUserID(userID uint) ([]*models.OrganizationMember, *application_types.ApplicationError)
+	FindMember(organizationID, userID uint) (*models.OrganizationMember, *application_types.ApplicationError)
+	ResolveActive(user *models.User, preferredOrganizationID uint) (*models.OrganizationMember, *application_types.ApplicationError)
+	ResolveUser(user *models.User, preferredOrganizationID, preferredBranchID uint) (*models.User, *application_types.ApplicationError)
+	ResolveUserIn(user *models.User, organizationID, branchID uint) (*models.User, *application_types.ApplicationError)
+	Find(organizationID uint) ([]*models.OrganizationMember, *application_types.ApplicationError)
+	FindBranches(member *models.OrganizationMember) ([]*models.Branch, *application_types.ApplicationError)
+	ResolveBranch(member *models.OrganizationMember, preferredBranchID uint) (uint, *application_types.ApplicationError)
+	Add(organizationID uint, memberDTO *dtos.OrganizationMemberDTO, actor dtos.Actor) (*models.Invitation, *application_types.ApplicationError)
+	UpdateByUserID(organizationID, userID uint, memberDTO *dtos.OrganizationMemberDTO, actor dtos.Actor) (*models.OrganizationMember, *application_types.ApplicationError)
+	RemoveByUserID(organizationID, userID uint, actor dtos.Actor) *application_types.ApplicationError
+}
+
+func NewOrganizationMemberService() OrganizationMemberService {
+	return &organizationMemberService{
+		userSvc:       NewUserService(),
+		invitationSvc: NewInvitationService(),
+		roleSvc:       NewRoleService(),
+		revocationSvc: NewTokenRevocationService(),
+		auditSvc:      NewAuditEventService(),
+		db:            db.Get(),
+	}
+}
+
+// FindByUserID returns the memberships of the user with their organizations.
+func (svc *organizationMemberService) FindByUserID(userID uint) ([]*models.OrganizationMember, *application_types.ApplicationError) {
+	logger.Info("Finding the organizations of the user id " + strconv.FormatUint(uint64(userID), 10))
+	var members []*models.OrganizationMember
+	if err := svc.db.Preload("Organization").Where("user_id = ?", userID).Order("id").Find(&members).Error; err != nil {
+		logger.Danger("Unable to find the organizations of the user. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization find failed!", err)
+	}
+
+	logger.Success("Organizations of the user found successfully")
+	return members, nil
+}
+
+func (svc *organizationMemberService) FindMember(organizationID, userID uint) (*models.OrganizationMember, *application_types.ApplicationError) {
+	member := &models.OrganizationMember{}
+	err := svc.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Warning("The user id " + strconv.FormatUint(uint64(userID), 10) + " is not a member of the organization id " + strconv.FormatUint(uint64(organizationID), 10))
+		return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No member found", fmt.Errorf("The user is not a member of the organization"))
+	}
+	if err != nil {
+		logger.Danger("Unable to find the organization member. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find the organization member", err)
+	}
+
+	return member, nil
+}
+
+// ResolveActive picks the organization a session of the user acts in: the
+// preferred one, else the one used last, else the oldest membership. It
+// returns nil for users who are not a member of any organization.
+func (svc *organizationMemberService) ResolveActive(user *models.User, preferredOrganizationID uint) (*models.OrganizationMember, *application_types.ApplicationError) {
+	for _, organizationID := range []uint{preferredOrganizationID, user.OrganizationIDOrZero()} {
+		if organizationID == 0 {
+			continue
+		}
+		member, appErr := svc.FindMember(organizationID, user.ID)
+		if appErr == nil {
+			return member, nil
+		}
+		if appErr.GetHTTPStatusCode() != http.StatusNotFound {
+			return nil, appErr
+		}
+	}
+
+	var member models.OrganizationMember
+	err := svc.db.Where("user_id = ?", user.ID).Order("id").First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Danger("Unable to find the organizations of the user. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find the organization member", err)
+	}
+
+	return &member, nil
+}
+
+// ResolveUser returns the user acting in the organization and the branch picked
+// by ResolveActive and ResolveBranch, as carried by their access tokens.
+func (svc *organizationMemberService) ResolveUser(user *models.User, preferredOrganizationID, preferredBranchID uint) (*models.User, *application_types.ApplicationError) {
+	member, appErr := svc.ResolveActive(user, preferredOrganizationID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	branchID, appErr := svc.ResolveBranch(member, preferredBranchID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return user.InOrganization(member).InBranch(branchID), nil
+}
+
+// ResolveUserIn returns the user acting in exactly the organization, unlike
+// ResolveUser which falls back to another one. It fails with a 403 error when
+// the user is no longer a member of it. The zero organization acts in none.
+func (svc *organizationMemberService) ResolveUserIn(user *models.User, organizationID, branchID uint) (*models.User, *application_types.ApplicationError) {
+	if organizationID == 0 {
+		return user.InOrganization(nil).InBranch(0), nil
+	}
+
+	member, appErr := svc.FindMember(organizationID, user.ID)
+	if appErr != nil {
+		if appErr.GetHTTPStatusCode() == http.StatusNotFound {
+			logger.Warning("The user id " + strconv.FormatUint(uint64(user.ID), 10) + " is no longer a member of the organization id " + strconv.FormatUint(uint64(organizationID), 10))
+			return nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+				fmt.Errorf("You are no longer a member of this organization"))
+		}
+		return nil, appErr
+	}
+	branchID, appErr = svc.ResolveBranch(member, branchID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return user.InOrganization(member).InBranch(branchID), nil
+}
+
+func (svc *organizationMemberService) Find(organizationID uint) ([]*models.OrganizationMember, *application_types.ApplicationError) {
+	logger.Info("Finding the members of the organization id " + strconv.FormatUint(uint64(organizationID), 10))
+	var members []*models.OrganizationMember
+	if err := svc.db.Preload("User").Preload("Branches").Where("organization_id = ?", organizationID).Order("id").Find(&members).Error; err != nil {
+		logger.Danger("Unable to find the organization members. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization member find failed!", err)
+	}
+
+	logger.Success("Organization members found successfully")
+	return members, nil
+}
+
+// FindBranches returns the branches the member may work in: the branches the
+// member is restricted to, else every branch of the organization.
+func (svc *organizationMemberService) FindBranches(member *models.OrganizationMember) ([]*models.Branch, *application_types.ApplicationError) {
+	var branches []*models.Branch
+	if err := svc.db.Model(member).Order("branches.name").Association("Branches").Find(&branches); err != nil {
+		logger.Danger("Unable to find the branches of the member. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch find failed!", err)
+	}
+	if len(branches) > 0 {
+		return branches, nil
+	}
+
+	if err := svc.db.Where("organization_id = ?", member.OrganizationID).Order("name").Find(&branches).Error; err != nil {
+		logger.Danger("Unable to find the branches of the organization. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Branch find failed!", err)
+	}
+	return branches, nil
+}
+
+// ResolveBranch picks the branch a session of the member acts in: the preferred
+// one when the member may work in it, else the first branch of a restricted
+// member. Members allowed in every branch act in all of them, which is zero.
+func (svc *organizationMemberService) ResolveBranch(member *models.OrganizationMember, preferredBranchID uint) (uint, *application_types.ApplicationError) {
+	if member == nil {
+		return 0, nil
+	}
+
+	var restricted []*models.Branch
+	if err := svc.db.Model(member).Order("branches.id").Association("Branches").Find(&restricted); err != nil {
+		logger.Danger("Unable to find the branches of the member. Gorm Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find the branches of the member", err)
+	}
+
+	if len(restricted) > 0 {
+		for _, branch := range restricted {
+			if branch.ID == preferredBranchID {
+				return branch.ID, nil
+			}
+		}
+		return restricted[0].ID, nil
+	}
+
+	if preferredBranchID == 0 {
+		return 0, nil
+	}
+	err := svc.db.Where("organization_id = ?", member.OrganizationID).First(&models.Branch{}, preferredBranchID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		logger.Danger("Unable to find the branch. Gorm Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find the branch", err)
+	}
+	return preferredBranchID, nil
+}
+
+// Add invites the email into the organization. Unknown emails are invited like
+// users created by an admin, users with an account are asked to join, and
+// either way the response is the invitation, so that it does not tell whether
+// the email has an account.
+func (svc *organizationMemberService) Add(organizationID uint, memberDTO *dtos.OrganizationMemberDTO, actor dtos.Actor) (*models.Invitation, *application_types.ApplicationError) {
+	logger.Info("Add organization member service started")
+	if appErr := svc.checkAssignableRole(organizationID, memberDTO.Role); appErr != nil {
+		logger.Danger("Add organization member service stopped")
+		return nil, appErr
+	}
+
+	email := strings.TrimSpace(memberDTO.Email)
+	// Validated for every email, as new users need them.
+	candidate := &models.User{Name: memberDTO.Name, Email: email, Phone: memberDTO.Phone, Role: memberDTO.Role, Status: models.UserStatusInactive}
+	if err := candidate.ValidateFields(); err != nil {
+		appErr := application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request",
+			fmt.Errorf("Validation failed for adding the member. Message: %w", err))
+		logger.Warning(appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	owner := memberDTO.Owner != nil && *memberDTO.Owner
+	var branches []*models.Branch
+	if memberDTO.BranchIDs != nil {
+		var appErr *application_types.ApplicationError
+		if branches, appErr = svc.findBranches(organizationID, *memberDTO.BranchIDs); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	user, appErr := svc.userSvc.FindByEmail(email)
+	if appErr != nil && !errors.Is(appErr.GetError(), gorm.ErrRecordNotFound) {
+		logger.Danger("Add organization member service stopped")
+		return nil, appErr
+	}
+
+	if user != nil {
+		if _, appErr := svc.FindMember(organizationID, user.ID); appErr == nil {
+			logger.Warning("Add organization member service stopped. Message: Already a member")
+			return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Organization member add failed",
+				fmt.Errorf("The user is already a member of the organization")).AddFieldErrors("email", "is already a member")
+		} else if appErr.GetHTTPStatusCode() != http.StatusNotFound {
+			return nil, appErr
+		}
+
+		invitation, appErr := svc.invitationSvc.ForOrganization(organizationID).InviteMember(user, memberDTO.Role, owner, branchIDs(branches), actor)
+		if appErr != nil {
+			logger.Danger("Add organization member service stopped")
+			return nil, appErr
+		}
+
+		logger.Success("Add organization member service success")
+		return invitation, nil
+	}
+
+	userDTO := &dtos.UserDTO{Name: memberDTO.Name, Email: email, Phone: memberDTO.Phone, Role: memberDTO.Role}
+	user, invitation, appErr := svc.invitationSvc.ForOrganization(organizationID).Invite(userDTO, actor)
+	if appErr != nil {
+		logger.Danger("Add organization member service stopped")
+		return nil, appErr
+	}
+
+	member, appErr := svc.FindMember(organizationID, user.ID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if owner {
+		if err := svc.db.Model(member).Update("owner", true).Error; err != nil {
+			logger.HighlightedDanger("Unable to make the member an owner. Gorm Message: " + err.Error())
+			return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization member add failed", err)
+		}
+	}
+	if memberDTO.BranchIDs != nil {
+		if appErr := svc.setBranches(member, *memberDTO.BranchIDs); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	invitation.Owner, invitation.BranchIDs = owner, branchIDs(member.Branches)
+	if err := svc.db.Model(invitation).Select("owner", "branch_ids").Updates(invitation).Error; err != nil {
+		logger.Warning("Unable to record the membership on the invitation. Gorm Message: " + err.Error())
+	}
+
+	svc.audit(models.AuditActionMemberAdded, member, actor, map[string]models.AuditChange{
+		"role":       {From: nil, To: member.Role},
+		"owner":      {From: nil, To: member.Owner},
+		"branch_ids": {From: nil, To: invitation.BranchIDs},
+	})
+
+	logger.Success("Add organization member service success")
+	return invitation, nil
+}
+
+// UpdateByUserID changes the role or the ownership of a member. The last owner
+// cannot give up the ownership.
+func (svc *organizationMemberService) UpdateByUserID(organizationID, userID uint, memberDTO *dtos.OrganizationMemberDTO, actor dtos.Actor) (*models.OrganizationMember, *application_types.ApplicationError) {
+	logger.Info("Update organization member service started for the user id " + strconv.FormatUint(uint64(userID), 10))
+	member, appErr := svc.FindMember(organizationID, userID)
+	if appErr != nil {
+		logger.Danger("Update organization member service stopped")
+		return nil, appErr
+	}
+	previous := *member
+	if err := svc.db.Model(member).Association("Branches").Find(&previous.Branches); err != nil {
+		logger.Danger("Unable to find the branches of the member. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization member update failed", err)
+	}
+	member.Branches = previous.Branches
+
+	if strings.TrimSpace(memberDTO.Role) != "" && memberDTO.Role != member.Role {
+		if appErr := svc.checkAssignableRole(organizationID, memberDTO.Role); appErr != nil {
+			logger.Danger("Update organization member service stopped")
+			return nil, appErr
+		}
+		member.Role = memberDTO.Role
+	}
+
+	if memberDTO.Owner != nil && *memberDTO.Owner != member.Owner {
+		if !*memberDTO.Owner {
+			if appErr := checkNotLastOwner(svc.db, organizationID, userID); appErr != nil {
+				return nil, appErr
+			}
+		}
+		member.Owner = *memberDTO.Owner
+	}
+
+	if err := svc.db.Omit("Branches").Save(member).Error; err != nil {
+		logger.HighlightedDanger("Unable to update the organization member. Gorm Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization member update failed", err)
+	}
+	if memberDTO.BranchIDs != nil {
+		if appErr := svc.setBranches(member, *memberDTO.BranchIDs); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	changes := map[string]models.AuditChange{}
+	if previous.Role != member.Role {
+		changes["role"] = models.AuditChange{From: previous.Role, To: member.Role}
+		// Access tokens carry the role.
+		svc.revocationSvc.RevokeUser(userID)
+	}
+	if previous.Owner != member.Owner {
+		changes["owner"] = models.AuditChange{From: previous.Owner, To: member.Owner}
+	}
+	if previousBranchIDs, branchIDs := branchIDs(previous.Branches), branchIDs(member.Branches); !slices.Equal(previousBranchIDs, branchIDs) {
+		changes["branch_ids"] = models.AuditChange{From: previousBranchIDs, To: branchIDs}
+		// Access tokens carry the branch.
+		svc.revocationSvc.RevokeUser(userID)
+	}
+	if len(changes) > 0 {
+		svc.audit(models.AuditActionMemberUpdated, member, actor, changes)
+	}
+
+	logger.Success("Update organization member service success")
+	return member, nil
+}
+
+// RemoveByUserID removes the member from the organization. The account of the
+// user is kept, so that they can still use their other organizations.
+func (svc *organizationMemberService) RemoveByUserID(organizationID, userID uint, actor dtos.Actor) *application_types.ApplicationError {
+	logger.Info("Remove organization member service started for the user id " + strconv.FormatUint(uint64(userID), 10))
+	member, appErr := svc.FindMember(organizationID, userID)
+	if appErr != nil {
+		logger.Danger("Remove organization member service stopped")
+		return appErr
+	}
+
+	if appErr := checkNotLastOwner(svc.db, organizationID, userID); appErr != nil {
+		return appErr
+	}
+
+	if err := removeOrganizationMember(svc.db, organizationID, userID); err != nil {
+		logger.HighlightedDanger("Unable to remove the organization member. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Organization member remove failed", err)
+	}
+	// The access tokens of the member may act in the organization.
+	svc.revocationSvc.RevokeUser(userID)
+
+	svc.audit(models.AuditActionMemberRemoved, member, actor, map[string]models.AuditChange{
+		"role": {From: member.Role, To: nil},
+	})
+
+	logger.Success("Remove organization member service success")
+	return nil
+}
+
+// checkAssignableRole allows any role of the organization except the roles
+// granting every permission, which are kept for platform administrators.
+func (svc *organizationMemberService) checkAssignableRole(organizationID uint, roleName string) *application_types.ApplicationError {
+	if strings.TrimSpace(roleName) == "" {
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invalid request",
+			fmt.Errorf("A role is required")).AddFieldErrors("role", "is required")
+	}
+
+	role, appErr := svc.roleSvc.ForOrganization(organizationID).FindByName(roleName)
+	if appErr != nil {
+		return appErr
+	}
+	if role.HasPermission(models.PermissionAll) {
+		logger.Warning("Access denied to assign the role '" + roleName + "' to an organization member")
+		return application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("The %s role cannot be assigned to organization members", roleName))
+	}
+
+	return nil
+}
+
+// setBranches restricts the member to the branches of the organization. No
+// branches allow every branch.
+func (svc *organizationMemberService) setBranches(member *models.OrganizationMember, ids []uint) *application_types.ApplicationError {
+	branches, appErr := svc.findBranches(member.OrganizationID, ids)
+	if appErr != nil {
+		return appErr
+	}
+
+	if err := svc.db.Model(member).Association("Branches").Replace(branches); err != nil {
+		logger.HighlightedDanger("Unable to set the branches of the member. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to set the branches of the member", err)
+	}
+	member.Branches = branches
+	return nil
+}
+
+// findBranches returns the branches of the organization with the ids, failing
+// for any id which is not one of them.
+func (svc *organizationMemberService) findBranches(organizationID uint, ids []uint) ([]*models.Branch, *application_types.ApplicationError) {
+	branches := []*models.Branch{}
+	if len(ids) > 0 {
+		if err := svc.db.Where("organization_id = ? AND id IN ?", organizationID, ids).Order("id").Find(&branches).Error; err != nil {
+			logger.Danger("Unable to find the branches. Gorm Message: " + err.Error())
+			return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find the branches", err)
+		}
+	}
+	for _, id := range ids {
+		if !slices.ContainsFunc(branches, func(branch *models.Branch) bool { return branch.ID == id }) {
+			logger.Warning("Unknown branch id " + strconv.FormatUint(uint64(id), 10) + " for the organization member")
+			return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Unknown branch",
+				fmt.Errorf("No branch found for the id %d", id)).AddFieldErrors("branch_ids", "must be branches of the organization")
+		}
+	}
+	return branches, nil
+}
+
+func branchIDs(branches []*models.Branch) []uint {
+	ids := make([]uint, 0, len(branches))
+	for _, branch := range branches {
+		ids = append(ids, branch.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (svc *organizationMemberService) audit(action string, member *models.OrganizationMember, actor dtos.Actor, changes map[string]models.AuditChange) {
+	event := newAuditEvent(action, models.AuditOutcomeSuccess, actor)
+	event.OrganizationID = &member.OrganizationID
+	event.TargetType, event.TargetID = models.AuditTargetUser, &member.UserID
+	event.Changes = changes
+	svc.auditSvc.Record(event)
+}
+
+// checkNotLastOwner stops the last owner of the organization from leaving it or
+// giving up the ownership.
+func checkNotLastOwner(tx *gorm.DB, organizationID, userID uint) *application_types.ApplicationError {
+	var owners, otherOwners int64
+	query := tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND owner = ?", organizationID, true)
+	if err := query.Session(&gorm.Session{}).Where("user_id = ?", userID).Count(&owners).Error; err != nil {
+		logger.Danger("Unable to count the organization owners. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to check the organization owners", err)
+	}
+	if owners == 0 {
+		return nil
+	}
+	if err := query.Session(&gorm.Session{}).Where("user_id <> ?", userID).Count(&otherOwners).Error; err != nil {
+		logger.Danger("Unable to count the organization owners. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to check the organization owners", err)
+	}
+	if otherOwners == 0 {
+		logger.Warning("Stopped removing the last owner of the organization id " + strconv.FormatUint(uint64(organizationID), 10))
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Last owner",
+			fmt.Errorf("An organization needs at least one owner. Make another member an owner first"))
+	}
+
+	return nil
+}
+
+func addOrganizationMember(tx *gorm.DB, member *models.OrganizationMember) error {
+	if err := member.ValidateFields(); err != nil {
+		return err
+	}
+	return tx.Create(member).Error
+}
+
+// removeOrganizationMember also forgets the organization as the one the user
+// logs in to.
+func removeOrganizationMember(tx *gorm.DB, organizationID, userID uint) error {
+	err := tx.Exec(`DELETE FROM organization_member_branches WHERE organization_member_id IN
+		(SELECT id FROM organization_members WHERE organization_id = ? AND user_id = ?)`, organizationID, userID).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("organization_id = ? AND user_id = ?", organizationID, userID).Delete(&models.OrganizationMember{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ? AND organization_id = ?", userID, organizationID).Update("organization_id", nil).Error
+}
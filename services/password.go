@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,32 +14,30 @@ import (
 )
 
 type passwordService struct {
-	db *gorm.DB
+	revocationSvc TokenRevocationService
+	db            *gorm.DB
 }
 
 type PasswordService interface {
 	Create(userID uint, plainPassword string) *application_types.ApplicationError
 	ChangePassword(userID uint, currentPassword, newPlainPassword string) *application_types.ApplicationError
 	ChangePasswordWithoutConfirmingCurrentPassword(userID uint, plainPassword string) *application_types.ApplicationError
+	VerifyCurrentPassword(userID uint, currentPassword, httpMessage string) *application_types.ApplicationError
 }
 
 func NewPasswordService() PasswordService {
 	return &passwordService{
-		db: db.Get(),
+		revocationSvc: NewTokenRevocationService(),
+		db:            db.Get(),
 	}
 }
 
 func (svc *passwordService) Create(userID uint, plainPassword string) *application_types.ApplicationError {
 	logger.Info("Password create service started")
-	password, err := auth.NewPassword(userID, plainPassword)
-	if err != nil {
-		logger.Info("Password create service started")
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password creation failed",
-			fmt.Errorf("Unable to create password for the userid "+strconv.FormatUint(uint64(userID), 10)+". Message: "+err.Error()))
-	}
-
-	if err := svc.db.Create(password).Error; err != nil {
-		fmt.Errorf("Unable to create password for the userid " + strconv.FormatUint(uint64(userID), 10) + ". Message: " + err.Error())
+	// auth.NewPassword already persists the new hash.
+	if _, err := auth.NewPassword(userID, plainPassword); err != nil {
+		logger.Danger("Password create service stopped")
+		return newPasswordError(err, "password", "Password creation failed")
 	}
 
 	logger.Success("Password create service success")
@@ -47,65 +46,64 @@ func (svc *passwordService) Create(userID uint, plainPassword string) *applicati
 
 func (svc *passwordService) ChangePassword(userID uint, currentPassword, newPlainPassword string) *application_types.ApplicationError {
 	logger.Info("Change Password service success")
-	password, err := auth.GetPasswordByUserID(userID)
-	if err != nil {
-		logger.Danger("Change Password service stopped.")
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password creation failed",
-			fmt.Errorf("Unable to find password for the userid "+strconv.FormatUint(uint64(userID), 10)+". Message: "+err.Error()))
-	}
-
-	if !password.VerifyPassword(currentPassword) {
+	if appErr := svc.VerifyCurrentPassword(userID, currentPassword, "Unable to change password"); appErr != nil {
 		logger.Danger("Change Password service stopped due to invalid current password.")
-		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Unable to change password", fmt.Errorf("Current password is not matching"))
-	}
-
-	if err := password.Delete(); err != nil {
-		logger.Danger("Change Password service stopped.")
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to change password", err)
+		return appErr
 	}
 
-	_, err = auth.NewPassword(userID, newPlainPassword)
+	// The current password is only replaced when the new one meets the policy.
+	_, err := auth.NewPassword(userID, newPlainPassword)
 	if err != nil {
 		logger.Danger("Change Password service stopped.")
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to change password", err)
+		return newPasswordError(err, "new_password", "Unable to change password")
 	}
 
+	svc.revocationSvc.RevokeUser(userID)
 	logger.Success("Change Password service success.")
 	return nil
 }
 
-func (svc *passwordService) ChangePasswordWithoutConfirmingCurrentPassword(userID uint, plainPassword string) *application_types.ApplicationError {
-	logger.Info("Change password without confirming current password service started.")
+// VerifyCurrentPassword re-authenticates the user before a sensitive change.
+// Accounts without a password cannot confirm one and have to reset it first.
+func (svc *passwordService) VerifyCurrentPassword(userID uint, currentPassword, httpMessage string) *application_types.ApplicationError {
 	password, err := auth.GetPasswordByUserID(userID)
 	if err != nil {
-		logger.Danger("Change password without confirming current password service Stopped.")
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password creation failed",
-			fmt.Errorf("Unable to find password for the userid "+strconv.FormatUint(uint64(userID), 10)+". Message: "+err.Error()))
+		logger.Danger("Unable to find the password of the user. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, httpMessage,
+			fmt.Errorf("Unable to find password for the userid %s. Message: %w", strconv.FormatUint(uint64(userID), 10), err))
 	}
 
-	if password == nil {
-		logger.Warning("Didnt find password for the user. Proceeding to create new one")
-	} else {
-		logger.Info("Deleting existing password for the user.")
-		err = password.Delete()
-		if err != nil {
-			logger.Warning("Password deleting stopped")
-			return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password deletion failed", err)
-		}
+	if password == nil || !password.VerifyPassword(currentPassword) {
+		logger.Warning("Invalid current password for the user id " + strconv.FormatUint(uint64(userID), 10))
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, httpMessage,
+			fmt.Errorf("Current password is not matching")).AddFieldErrors("current_password", "is not matching")
 	}
+	return nil
+}
 
-	password, err = auth.NewPassword(userID, plainPassword)
+func (svc *passwordService) ChangePasswordWithoutConfirmingCurrentPassword(userID uint, plainPassword string) *application_types.ApplicationError {
+	logger.Info("Change password without confirming current password service started.")
+	// auth.NewPassword replaces the existing password, if any.
+	_, err := auth.NewPassword(userID, plainPassword)
 	if err != nil {
 		logger.Danger("Change password without confirming current password service Stopped.")
-		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password creation failed",
-			fmt.Errorf("Unable to create password for the userid "+strconv.FormatUint(uint64(userID), 10)+". Message: "+err.Error()))
-	}
-
-	if err := svc.db.Create(password).Error; err != nil {
-		logger.Danger("Change password without confirming current password service Stopped.")
-		fmt.Errorf("Unable to create password for the userid " + strconv.FormatUint(uint64(userID), 10) + ". Message: " + err.Error())
+		return newPasswordError(err, "password", "Password creation failed")
 	}
 
+	svc.revocationSvc.RevokeUser(userID)
 	logger.Success("Change password without confirming current password service success.")
 	return nil
 }
+
+// newPasswordError reports a broken password policy as field errors of the
+// given request field, and any other failure as an internal error.
+func newPasswordError(err error, field, httpMessage string) *application_types.ApplicationError {
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		logger.Warning("Password rejected by the password policy. Message: " + policyErr.Error())
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, httpMessage, policyErr).
+			AddFieldErrors(field, policyErr.Violations...)
+	}
+
+	return application_types.NewApplicationError(false, http.StatusInternalServerError, httpMessage, err)
+}
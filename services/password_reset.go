@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/mailer"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+const defaultPasswordResetTokenTTL = 30 * time.Minute
+
+type passwordResetService struct {
+	userSvc       UserService
+	revocationSvc TokenRevocationService
+	sessSvc       SessionService
+	mailer        mailer.Mailer
+	db            *gorm.DB
+}
+
+type PasswordResetService interface {
+	RequestPasswordReset(email string) *application_types.ApplicationError
+	ResetPassword(token, newPassword, confirmPassword string) *application_types.ApplicationError
+}
+
+func NewPasswordResetService() PasswordResetService {
+	return &passwordResetService{
+		userSvc:       NewUserService(),
+		revocationSvc: NewTokenRevocationService(),
+		sessSvc:       NewSessionService(),
+		mailer:        mailer.New(),
+		db:            db.Get(),
+	}
+}
+
+func (svc *passwordResetService) RequestPasswordReset(email string) *application_types.ApplicationError {
+	logger.Info("Password reset request service started")
+	user, appErr := svc.userSvc.FindByEmail(email)
+	if appErr != nil {
+		if errors.Is(appErr.GetError(), gorm.ErrRecordNotFound) {
+			// Do not reveal whether the email is registered.
+			logger.Warning("Password reset requested for an unregistered email")
+			return nil
+		}
+		logger.Danger("Password reset request service stopped")
+		return appErr
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate password reset token. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password reset request failed", err)
+	}
+
+	err = svc.db.Transaction(func(tx *gorm.DB) error {
+		// Only the latest reset link stays usable.
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(passwordResetTokenTTL()),
+		}).Error
+	})
+	if err != nil {
+		logger.HighlightedDanger("Unable to store password reset token. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password reset request failed", err)
+	}
+
+	link := os.Getenv("APP_BASE_URL") + "/reset-password?token=" + token
+	body := "Hi " + user.Name + ",\n\n" +
+		"We received a request to reset your Treeforms Billing password. Use the link below to choose a new password:\n\n" +
+		link + "\n\n" +
+		"The link expires in " + passwordResetTokenTTL().String() + " and can be used only once. " +
+		"If you did not request a password reset, you can ignore this email.\n"
+
+	if err := svc.mailer.Send(user.Email, "Reset your Treeforms Billing password", body); err != nil {
+		// Answer as for an unregistered email, not to reveal the account.
+		logger.HighlightedDanger("Unable to send password reset email. Message: " + err.Error())
+		return nil
+	}
+
+	logger.Success("Password reset request service success")
+	return nil
+}
+
+func (svc *passwordResetService) ResetPassword(token, newPassword, confirmPassword string) *application_types.ApplicationError {
+	logger.Info("Password reset service started")
+	if newPassword != confirmPassword {
+		logger.Danger("Confirm password and password are not identical.")
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Password reset failed", fmt.Errorf("Password and Confirm passwords are not identical"))
+	}
+
+	var resetToken models.PasswordResetToken
+	if err := svc.db.Where("token_hash = ? AND used_at IS NULL", auth.HashOpaqueToken(token)).First(&resetToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("Invalid or already used password reset token")
+			return application_types.NewApplicationError(false, http.StatusUnauthorized, "Password reset failed", fmt.Errorf("Invalid or already used reset token"))
+		}
+		logger.HighlightedDanger("Unable to find password reset token. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Password reset failed", err)
+	}
+
+	if resetToken.ExpiresAt.Before(time.Now()) {
+		logger.Warning("Expired password reset token.")
+		return application_types.NewApplicationError(false, http.StatusUnauthorized, "Password reset failed", fmt.Errorf("Your reset token is expired"))
+	}
+
+	// The token is consumed together with the new password, so a password
+	// rejected by the policy leaves the emailed link usable.
+	errResetTokenConsumed := errors.New("Invalid or already used reset token")
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		// Consumed first so a concurrent request cannot reuse it.
+		res := tx.Model(&models.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", resetToken.ID).Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errResetTokenConsumed
+		}
+
+		_, err := auth.NewPasswordWithTx(tx, resetToken.UserID, newPassword)
+		return err
+	})
+	if errors.Is(err, errResetTokenConsumed) {
+		logger.Warning("Password reset token consumed by another request")
+		return application_types.NewApplicationError(false, http.StatusUnauthorized, "Password reset failed", err)
+	}
+	if err != nil {
+		logger.Danger("Password reset service stopped. Message: " + err.Error())
+		return newPasswordError(err, "password", "Password reset failed")
+	}
+	svc.revocationSvc.RevokeUser(resetToken.UserID)
+
+	if appErr := svc.sessSvc.RevokeAll(resetToken.UserID); appErr != nil {
+		logger.Danger("Password reset service stopped")
+		return appErr
+	}
+
+	logger.Success("Password reset service success for the user id " + strconv.FormatUint(uint64(resetToken.UserID), 10))
+	return nil
+}
+
+func passwordResetTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultPasswordResetTokenTTL
+	}
+	return ttl
+}
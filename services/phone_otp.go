@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/auth"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/sms"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	phoneOTPLength       = 6
+	phoneOTPTTL          = 5 * time.Minute
+	phoneOTPMaxAttempts  = 5
+	phoneOTPResendPeriod = time.Minute
+	phoneOTPKeyPrefix    = "phone_otp:"
+	phoneOTPCooldownKey  = "phone_otp_cooldown:"
+)
+
+type phoneOTPService struct {
+	userSvc UserService
+	sender  sms.Sender
+}
+
+type PhoneOTPService interface {
+	RequestOTP(phone string) *application_types.ApplicationError
+	VerifyOTP(phone, code string) (uint, *application_types.ApplicationError)
+}
+
+func NewPhoneOTPService() PhoneOTPService {
+	return &phoneOTPService{
+		userSvc: NewUserService(),
+		sender:  sms.New(),
+	}
+}
+
+// RequestOTP sends a one time password to the phone of a registered user. The
+// OTP is kept hashed in Redis together with the number of failed attempts.
+// Registered and unregistered phones get the same responses, so the cooldown
+// applies to every phone and failures after the lookup are only logged.
+func (svc *phoneOTPService) RequestOTP(phone string) *application_types.ApplicationError {
+	logger.Info("Request phone OTP service started")
+	phone = strings.TrimSpace(phone)
+	ctx := context.Background()
+
+	allowed, err := db.GetRedis().SetNX(ctx, phoneOTPCooldownKey+phone, 1, phoneOTPResendPeriod).Result()
+	if err != nil {
+		logger.HighlightedDanger("Unable to check phone OTP cooldown. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "OTP request failed", err)
+	}
+	if !allowed {
+		logger.Warning("Request phone OTP service stopped. Message: Requested too soon.")
+		return application_types.NewApplicationError(false, http.StatusTooManyRequests, "OTP request failed",
+			fmt.Errorf("An OTP was sent recently. Please wait a minute before requesting another one")).
+			SetHeader("Retry-After", strconv.Itoa(int(phoneOTPResendPeriod.Seconds())))
+	}
+
+	user, appErr := svc.userSvc.FindByPhone(phone)
+	if appErr != nil {
+		if errors.Is(appErr.GetError(), gorm.ErrRecordNotFound) {
+			// Do not reveal whether the phone is registered.
+			logger.Warning("Phone OTP requested for an unregistered phone")
+			return nil
+		}
+		return appErr
+	}
+
+	code, err := newNumericCode(phoneOTPLength)
+	if err != nil {
+		logger.HighlightedDanger("Unable to generate phone OTP. Message: " + err.Error())
+		return nil
+	}
+
+	key := phoneOTPKeyPrefix + phone
+	pipe := db.GetRedis().TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "code_hash", phoneOTPHash(phone, code), "attempts", 0, "user_id", user.ID)
+	pipe.Expire(ctx, key, phoneOTPTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.HighlightedDanger("Unable to store phone OTP. Message: " + err.Error())
+		return nil
+	}
+
+	message := code + " is your Treeforms Billing login code. It expires in " + strconv.Itoa(int(phoneOTPTTL.Minutes())) + " minutes. Do not share it with anyone."
+	if err := svc.sender.Send(phone, message); err != nil {
+		logger.HighlightedDanger("Unable to send phone OTP. Message: " + err.Error())
+		return nil
+	}
+
+	logger.Success("Request phone OTP service success")
+	return nil
+}
+
+// verifyPhoneOTPScript counts the attempt and checks the OTP in one step, so
+// that concurrent requests cannot skip the attempt limit or use the OTP twice.
+// A correct OTP is consumed together with the check.
+var verifyPhoneOTPScript = redis.NewScript(`
+local codeHash = redis.call('HGET', KEYS[1], 'code_hash')
+if not codeHash then
+	redis.call('DEL', KEYS[1])
+	return {'missing'}
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts > tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return {'locked'}
+end
+if codeHash ~= ARGV[1] then
+	return {'invalid'}
+end
+local userID = redis.call('HGET', KEYS[1], 'user_id')
+redis.call('DEL', KEYS[1])
+return {'valid', userID}
+`)
+
+// VerifyOTP checks the OTP and returns the id of the user it was sent to. The
+// OTP is dropped after a successful check or too many failed attempts.
+func (svc *phoneOTPService) VerifyOTP(phone, code string) (uint, *application_types.ApplicationError) {
+	logger.Info("Verify phone OTP service started")
+	phone = strings.TrimSpace(phone)
+	ctx := context.Background()
+	key := phoneOTPKeyPrefix + phone
+
+	result, err := verifyPhoneOTPScript.Run(ctx, db.GetRedis(), []string{key}, phoneOTPHash(phone, strings.TrimSpace(code)), phoneOTPMaxAttempts).StringSlice()
+	if err != nil {
+		logger.HighlightedDanger("Unable to verify phone OTP. Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "OTP verification failed", err)
+	}
+
+	switch result[0] {
+	case "missing":
+		logger.Warning("No active phone OTP")
+		return 0, application_types.NewApplicationError(false, http.StatusUnauthorized, "OTP verification failed", fmt.Errorf("Invalid or expired OTP. Please request a new one"))
+	case "locked":
+		logger.Warning("Too many phone OTP attempts. Dropped the OTP.")
+		return 0, application_types.NewApplicationError(false, http.StatusTooManyRequests, "OTP verification failed", fmt.Errorf("Too many invalid attempts. Please request a new OTP"))
+	case "invalid":
+		logger.Warning("Invalid phone OTP")
+		return 0, application_types.NewApplicationError(false, http.StatusUnauthorized, "OTP verification failed", fmt.Errorf("Invalid OTP"))
+	}
+
+	if len(result) < 2 {
+		logger.HighlightedDanger("Corrupted phone OTP. Message: No user id")
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "OTP verification failed", fmt.Errorf("Corrupted OTP"))
+	}
+	userID, err := strconv.ParseUint(result[1], 10, 0)
+	if err != nil {
+		logger.HighlightedDanger("Corrupted phone OTP. Message: " + err.Error())
+		return 0, application_types.NewApplicationError(false, http.StatusInternalServerError, "OTP verification failed", err)
+	}
+
+	logger.Success("Verify phone OTP service success")
+	return uint(userID), nil
+}
+
+func phoneOTPHash(phone, code string) string {
+	return auth.HashOpaqueToken(phone + ":" + code)
+}
+
+func newNumericCode(length int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < length; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/dtos"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+const rolePermissionsCacheTTL = 30 * time.Second
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+// rolePermissionsCache keeps the permissions of a role for a short while, so
+// that resolving them on every request does not hit the database.
+var rolePermissionsCache = struct {
+	sync.Mutex
+	entries map[string]rolePermissionsCacheEntry
+}{entries: map[string]rolePermissionsCacheEntry{}}
+
+type rolePermissionsCacheEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+type roleService struct {
+	db *gorm.DB
+	// organizationID adds the custom roles of the organization to the shared
+	// roles, see ForOrganization.
+	organizationID uint
+}
+
+type RoleService interface {
+	ForOrganization(organizationID uint) RoleService
+	Find() ([]*models.Role, *application_types.ApplicationError)
+	FindByID(id uint) (*models.Role, *application_types.ApplicationError)
+	FindByName(name string) (*models.Role, *application_types.ApplicationError)
+	Create(roleDTO *dtos.RoleDTO, actorRole string) (*models.Role, *application_types.ApplicationError)
+	UpdateByID(id uint, roleDTO *dtos.RoleDTO, actorRole string) (*models.Role, *application_types.ApplicationError)
+	DeleteByID(id uint, actorRole string) *application_types.ApplicationError
+	Permissions(roleName string) ([]string, *application_types.ApplicationError)
+	CanManageRole(actorRole, targetRole string) (bool, *application_types.ApplicationError)
+}
+
+func NewRoleService() RoleService {
+	return &roleService{
+		db: db.Get(),
+	}
+}
+
+// ForOrganization returns the service for the roles available in the
+// organization: the built-in and shared roles, and the custom roles of the
+// organization, which take precedence over shared roles of the same name. New
+// roles are created in the organization. The zero organization only sees the
+// shared roles.
+func (svc *roleService) ForOrganization(organizationID uint) RoleService {
+	scoped := *svc
+	scoped.organizationID = organizationID
+	return &scoped
+}
+
+func (svc *roleService) scoped() *gorm.DB {
+	if svc.organizationID == 0 {
+		return svc.db.Where("organization_id IS NULL")
+	}
+	return svc.db.Where("organization_id IS NULL OR organization_id = ?", svc.organizationID)
+}
+
+func (svc *roleService) Find() ([]*models.Role, *application_types.ApplicationError) {
+	logger.Info("Finding roles")
+	var roles []*models.Role
+	if err := svc.scoped().Order("built_in DESC, name").Find(&roles).Error; err != nil {
+		logger.Danger("Unable to find roles. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Role find failed!", err)
+	}
+
+	logger.Success("Roles found successfully")
+	return roles, nil
+}
+
+func (svc *roleService) FindByID(id uint) (*models.Role, *application_types.ApplicationError) {
+	role := &models.Role{}
+	if err := svc.scoped().First(role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Danger("No role found for the id " + strconv.FormatUint(uint64(id), 10))
+			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No role found for the given id", err)
+		}
+		logger.Danger("Unable to find role by id. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find role with id", err)
+	}
+
+	return role, nil
+}
+
+func (svc *roleService) FindByName(name string) (*models.Role, *application_types.ApplicationError) {
+	role := &models.Role{}
+	if err := svc.scoped().Where("name = ?", name).Order("organization_id IS NULL").First(role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("No role found with the name " + name)
+			return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Unknown role",
+				fmt.Errorf("The role %s does not exist: %w", name, err))
+		}
+		logger.Danger("Unable to find role by name. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find role", err)
+	}
+
+	return role, nil
+}
+
+func (svc *roleService) Create(roleDTO *dtos.RoleDTO, actorRole string) (*models.Role, *application_types.ApplicationError) {
+	logger.Info("Creating a new role.")
+	role := &models.Role{
+		Name:        strings.TrimSpace(roleDTO.Name),
+		Description: roleDTO.Description,
+		Permissions: roleDTO.Permissions,
+	}
+	if svc.organizationID != 0 {
+		role.OrganizationID = &svc.organizationID
+	} else if appErr := svc.requireSharedRoleManager(actorRole); appErr != nil {
+		logger.Warning("Create role service stopped. Message: " + appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	if appErr := svc.validate(role, actorRole); appErr != nil {
+		logger.Warning("Create role service stopped. Message: " + appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	if err := svc.scoped().Where("name = ?", role.Name).First(&models.Role{}).Error; err == nil {
+		logger.Warning("Given role name already in use")
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Role creation failed",
+			fmt.Errorf("A role named %s already exists", role.Name)).AddFieldErrors("name", "is already taken")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Danger("Unable to check the role name. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Role creation failed", err)
+	}
+
+	if err := svc.db.Create(role).Error; err != nil {
+		logger.Danger("Role creation failed. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Role creation failed", err)
+	}
+
+	logger.Success("Role created succesfully.")
+	return role, nil
+}
+
+// UpdateByID changes the description and permissions of a custom role. Names
+// are immutable since users reference roles by name.
+func (svc *roleService) UpdateByID(id uint, roleDTO *dtos.RoleDTO, actorRole string) (*models.Role, *application_types.ApplicationError) {
+	logger.Info("Started updating role by id " + strconv.FormatUint(uint64(id), 10))
+	role, appErr := svc.findCustom(id, actorRole)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if roleDTO.Name != "" && roleDTO.Name != role.Name {
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Role update failed",
+			fmt.Errorf("Role names cannot be changed")).AddFieldErrors("name", "cannot be changed")
+	}
+	if roleDTO.Description != "" {
+		role.Description = roleDTO.Description
+	}
+	if roleDTO.Permissions != nil {
+		role.Permissions = roleDTO.Permissions
+	}
+
+	if appErr := svc.validate(role, actorRole); appErr != nil {
+		logger.Warning("Update role service stopped. Message: " + appErr.GetErrorMessage())
+		return nil, appErr
+	}
+
+	if err := svc.db.Save(role).Error; err != nil {
+		logger.Danger("Role update failed. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Role update failed", err)
+	}
+	forgetRolePermissions(role.Name)
+
+	logger.Success("Role updated by id " + strconv.FormatUint(uint64(id), 10))
+	return role, nil
+}
+
+func (svc *roleService) DeleteByID(id uint, actorRole string) *application_types.ApplicationError {
+	logger.Info("Deleting a role with id " + strconv.FormatUint(uint64(id), 10))
+	role, appErr := svc.findCustom(id, actorRole)
+	if appErr != nil {
+		return appErr
+	}
+
+	var holders, members int64
+	if role.OrganizationID == nil {
+		if err := svc.db.Model(&models.User{}).Where("role = ?", role.Name).Count(&holders).Error; err != nil {
+			logger.Danger("Unable to count the users of the role. Message: " + err.Error())
+			return application_types.NewApplicationError(false, http.StatusInternalServerError, "Role deletion failed", err)
+		}
+	}
+	membersQuery := svc.db.Model(&models.OrganizationMember{}).Where("role = ?", role.Name)
+	if role.OrganizationID != nil {
+		membersQuery = membersQuery.Where("organization_id = ?", *role.OrganizationID)
+	}
+	if err := membersQuery.Count(&members).Error; err != nil {
+		logger.Danger("Unable to count the members of the role. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Role deletion failed", err)
+	}
+	holders += members
+	if holders > 0 {
+		logger.Warning("Role deletion stopped. Message: Role still assigned")
+		return application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Role deletion failed",
+			fmt.Errorf("The role is still assigned to %d users", holders))
+	}
+
+	if err := svc.db.Unscoped().Delete(role).Error; err != nil {
+		logger.Danger("Role deletion failed. Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Role deletion failed", err)
+	}
+	forgetRolePermissions(role.Name)
+
+	logger.Success("Role deleted by id " + strconv.FormatUint(uint64(id), 10))
+	return nil
+}
+
+// Permissions resolves the effective permissions of a role name.
+func (svc *roleService) Permissions(roleName string) ([]string, *application_types.ApplicationError) {
+	key := strconv.FormatUint(uint64(svc.organizationID), 10) + ":" + roleName
+	rolePermissionsCache.Lock()
+	entry, ok := rolePermissionsCache.entries[key]
+	rolePermissionsCache.Unlock()
+	if ok && entry.expiresAt.After(time.Now()) {
+		return entry.permissions, nil
+	}
+
+	role, appErr := svc.FindByName(roleName)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	rolePermissionsCache.Lock()
+	rolePermissionsCache.entries[key] = rolePermissionsCacheEntry{permissions: role.Permissions, expiresAt: time.Now().Add(rolePermissionsCacheTTL)}
+	rolePermissionsCache.Unlock()
+	return role.Permissions, nil
+}
+
+func (svc *roleService) CanManageRole(actorRole, targetRole string) (bool, *application_types.ApplicationError) {
+	actorPermissions, appErr := svc.Permissions(actorRole)
+	if appErr != nil {
+		return false, appErr
+	}
+	targetPermissions, appErr := svc.Permissions(targetRole)
+	if appErr != nil {
+		return false, appErr
+	}
+
+	return models.CanManageRole(&models.Role{Permissions: actorPermissions}, &models.Role{Permissions: targetPermissions}), nil
+}
+
+func (svc *roleService) findCustom(id uint, actorRole string) (*models.Role, *application_types.ApplicationError) {
+	role, appErr := svc.FindByID(id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if role.BuiltIn {
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Built-in role",
+			fmt.Errorf("Built-in roles cannot be changed or deleted"))
+	}
+	if role.OrganizationID == nil {
+		if appErr := svc.requireSharedRoleManager(actorRole); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	canManage, appErr := svc.CanManageRole(actorRole, role.Name)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !canManage {
+		return nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("Your role cannot manage the role %s", role.Name))
+	}
+
+	return role, nil
+}
+
+// requireSharedRoleManager allows changes to the shared roles, which every
+// organization sees, only to platform administrators holding every permission.
+func (svc *roleService) requireSharedRoleManager(actorRole string) *application_types.ApplicationError {
+	actorPermissions, appErr := svc.Permissions(actorRole)
+	if appErr != nil {
+		return appErr
+	}
+	if !slices.Contains(actorPermissions, models.PermissionAll) {
+		return application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("Shared roles can only be changed by platform administrators"))
+	}
+	return nil
+}
+
+// validate checks the fields of the role and that the actor does not grant
+// permissions they do not hold themselves.
+func (svc *roleService) validate(role *models.Role, actorRole string) *application_types.ApplicationError {
+	appErr := application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "Invalid role", fmt.Errorf("Validation failed for the role"))
+	valid := true
+
+	if !roleNamePattern.MatchString(role.Name) {
+		appErr.AddFieldErrors("name", "must be 2 to 50 lowercase letters, digits, dashes or underscores, starting with a letter")
+		valid = false
+	}
+	if len(role.Permissions) == 0 {
+		appErr.AddFieldErrors("permissions", "must contain at least one permission")
+		valid = false
+	}
+	for _, permission := range role.Permissions {
+		if !slices.Contains(models.Permissions, permission) {
+			appErr.AddFieldErrors("permissions", "unknown permission "+permission)
+			valid = false
+		}
+	}
+	if !valid {
+		return appErr
+	}
+
+	actorPermissions, permErr := svc.Permissions(actorRole)
+	if permErr != nil {
+		return permErr
+	}
+	if !models.CanManageRole(&models.Role{Permissions: actorPermissions}, role) {
+		return application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+			fmt.Errorf("A role can only be granted a subset of your own permissions"))
+	}
+
+	return nil
+}
+
+// forgetRolePermissions forgets the role in every organization, since a shared
+// role is resolved in all of them.
+func forgetRolePermissions(roleName string) {
+	rolePermissionsCache.Lock()
+	for key := range rolePermissionsCache.entries {
+		if strings.HasSuffix(key, ":"+roleName) {
+			delete(rolePermissionsCache.entries, key)
+		}
+	}
+	rolePermissionsCache.Unlock()
+}
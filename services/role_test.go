@@ -0,0 +1,91 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+	"treeforms_billing/models"
+)
+
+// cacheRolePermissions makes the role resolvable without a database.
+func cacheRolePermissions(t *testing.T, organizationID uint, roleName string, permissions ...string) {
+	t.Helper()
+	key := strconv.FormatUint(uint64(organizationID), 10) + ":" + roleName
+	rolePermissionsCache.Lock()
+	rolePermissionsCache.entries[key] = rolePermissionsCacheEntry{permissions: permissions, expiresAt: time.Now().Add(time.Hour)}
+	rolePermissionsCache.Unlock()
+	t.Cleanup(func() { forgetRolePermissions(roleName) })
+}
+
+func TestRoleServiceValidate(t *testing.T) {
+	svc := &roleService{organizationID: 7}
+	cacheRolePermissions(t, 7, "test-superadmin", models.PermissionAll)
+	cacheRolePermissions(t, 7, "test-admin", models.PermissionUserRead, models.PermissionUserCreate, models.PermissionRoleManage)
+
+	tests := []struct {
+		name       string
+		actorRole  string
+		role       *models.Role
+		wantStatus int
+		wantField  string
+	}{
+		{"subset of the own permissions", "test-admin", &models.Role{Name: "clerk", Permissions: []string{models.PermissionUserRead}}, 0, ""},
+		{"every permission grants anything", "test-superadmin", &models.Role{Name: "auditor", Permissions: []string{models.PermissionAuditRead}}, 0, ""},
+		{"invalid name", "test-admin", &models.Role{Name: "Clerk", Permissions: []string{models.PermissionUserRead}}, http.StatusUnprocessableEntity, "name"},
+		{"no permissions", "test-admin", &models.Role{Name: "clerk"}, http.StatusUnprocessableEntity, "permissions"},
+		{"unknown permission", "test-admin", &models.Role{Name: "clerk", Permissions: []string{"user:fly"}}, http.StatusUnprocessableEntity, "permissions"},
+		{"the all permission cannot be granted", "test-admin", &models.Role{Name: "clerk", Permissions: []string{models.PermissionAll}}, http.StatusUnprocessableEntity, "permissions"},
+		{"permission the actor does not hold", "test-admin", &models.Role{Name: "clerk", Permissions: []string{models.PermissionAuditRead}}, http.StatusForbidden, ""},
+		{"same permissions as the actor", "test-admin", &models.Role{Name: "clerk", Permissions: []string{models.PermissionUserRead, models.PermissionUserCreate, models.PermissionRoleManage}}, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := svc.validate(tt.role, tt.actorRole)
+			if tt.wantStatus == 0 {
+				if appErr != nil {
+					t.Fatalf("validate() = %v, want no error", appErr.GetErrorMessage())
+				}
+				return
+			}
+			if appErr == nil {
+				t.Fatalf("validate() = nil, want status %d", tt.wantStatus)
+			}
+			if appErr.GetHTTPStatusCode() != tt.wantStatus {
+				t.Errorf("validate() status = %d, want %d", appErr.GetHTTPStatusCode(), tt.wantStatus)
+			}
+			if tt.wantField != "" && len(appErr.GetFieldErrors()[tt.wantField]) == 0 {
+				t.Errorf("validate() has no field error for %s", tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRoleServiceRequireSharedRoleManager(t *testing.T) {
+	// Platform administrators of legacy accounts act outside of organizations.
+	svc := &roleService{}
+	cacheRolePermissions(t, 0, "test-superadmin", models.PermissionAll)
+	cacheRolePermissions(t, 0, "test-admin", models.PermissionRoleRead, models.PermissionRoleManage)
+
+	tests := []struct {
+		name      string
+		actorRole string
+		wantErr   bool
+	}{
+		{"every permission changes shared roles", "test-superadmin", false},
+		{"role managers cannot change shared roles", "test-admin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := svc.requireSharedRoleManager(tt.actorRole)
+			if (appErr != nil) != tt.wantErr {
+				t.Fatalf("requireSharedRoleManager() = %v, want error %v", appErr, tt.wantErr)
+			}
+			if appErr != nil && appErr.GetHTTPStatusCode() != http.StatusForbidden {
+				t.Errorf("requireSharedRoleManager() status = %d, want %d", appErr.GetHTTPStatusCode(), http.StatusForbidden)
+			}
+		})
+	}
+}
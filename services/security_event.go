@@ -0,0 +1,36 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+type securityEventService struct {
+	db *gorm.DB
+}
+
+type SecurityEventService interface {
+	Record(event *models.SecurityEvent) *application_types.ApplicationError
+}
+
+func NewSecurityEventService() SecurityEventService {
+	return &securityEventService{
+		db: db.Get(),
+	}
+}
+
+func (svc *securityEventService) Record(event *models.SecurityEvent) *application_types.ApplicationError {
+	logger.HighlightedDanger("Security event '" + event.Type + "' for the user id " + strconv.FormatUint(uint64(event.UserID), 10) + ". " + event.Details)
+	if err := svc.db.Create(event).Error; err != nil {
+		logger.HighlightedDanger("Unable to record security event. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to record security event", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"gorm.io/gorm"
+)
+
+type sessionService struct {
+	revocationSvc TokenRevocationService
+	db            *gorm.DB
+}
+
+// SessionService manages login sessions. A session is a refresh token family
+// and is identified by its family id.
+type SessionService interface {
+	FindByUserID(userID uint) ([]*models.RefreshToken, *application_types.ApplicationError)
+	RevokeByID(userID uint, sessionID string) *application_types.ApplicationError
+	RevokeAll(userID uint) *application_types.ApplicationError
+	RevokeFamily(familyID string, reason string) *application_types.ApplicationError
+}
+
+func NewSessionService() SessionService {
+	return &sessionService{
+		revocationSvc: NewTokenRevocationService(),
+		db:            db.Get(),
+	}
+}
+
+func (svc *sessionService) FindByUserID(userID uint) ([]*models.RefreshToken, *application_types.ApplicationError) {
+	logger.Info("Finding sessions of the user id " + strconv.FormatUint(uint64(userID), 10))
+	var sessions []*models.RefreshToken
+	if err := svc.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		logger.Danger("Unable to find sessions. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Session find failed!", err)
+	}
+
+	logger.Success("Sessions found successfully")
+	return sessions, nil
+}
+
+func (svc *sessionService) RevokeByID(userID uint, sessionID string) *application_types.ApplicationError {
+	logger.Info("Revoking the session " + sessionID)
+	res := svc.revoke(svc.db.Where("family_id = ? AND user_id = ?", sessionID, userID), models.RefreshTokenRevokedByUser)
+	if res.Error != nil {
+		logger.Danger("Unable to revoke session. Message: " + res.Error.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Session revoke failed", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		logger.Warning("No active session found for the id " + sessionID)
+		return application_types.NewApplicationError(false, http.StatusNotFound, "No session found for the given id", fmt.Errorf("Session not found"))
+	}
+
+	svc.revocationSvc.RevokeSession(sessionID)
+	logger.Success("Revoked the session " + sessionID)
+	return nil
+}
+
+func (svc *sessionService) RevokeAll(userID uint) *application_types.ApplicationError {
+	logger.Info("Revoking all sessions of the user id " + strconv.FormatUint(uint64(userID), 10))
+	if err := svc.revoke(svc.db.Where("user_id = ?", userID), models.RefreshTokenRevokedByUser).Error; err != nil {
+		logger.HighlightedDanger("Unable to revoke sessions. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to revoke sessions", err)
+	}
+
+	svc.revocationSvc.RevokeUser(userID)
+	logger.Success("Revoked all sessions of the user id " + strconv.FormatUint(uint64(userID), 10))
+	return nil
+}
+
+func (svc *sessionService) RevokeFamily(familyID string, reason string) *application_types.ApplicationError {
+	logger.Info("Revoking the session " + familyID + ". Reason: " + reason)
+	if err := svc.revoke(svc.db.Where("family_id = ?", familyID), reason).Error; err != nil {
+		logger.HighlightedDanger("Unable to revoke session. Gorm Message: " + err.Error())
+		return application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to revoke session", err)
+	}
+
+	svc.revocationSvc.RevokeSession(familyID)
+	logger.Success("Revoked the session " + familyID)
+	return nil
+}
+
+// revoke marks the still active refresh tokens matched by query as revoked. The
+// rows are kept so that a later replay can be recognised.
+func (svc *sessionService) revoke(query *gorm.DB, reason string) *gorm.DB {
+	return query.Model(&models.RefreshToken{}).Where("revoked_at IS NULL").Updates(map[string]interface{}{
+		"revoked_at":     time.Now(),
+		"revoked_reason": reason,
+	})
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"treeforms_billing/application_types"
+	"treeforms_billing/db"
+	"treeforms_billing/logger"
+	"treeforms_billing/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	accessTokenDenylistKeyPrefix = "access_token_denylist:"
+	userAccessTokensKeyPrefix    = "access_tokens:user:"
+	sessionAccessTokensKeyPrefix = "access_tokens:session:"
+)
+
+type tokenRevocationService struct{}
+
+// TokenRevocationService keeps a Redis denylist of access token ids (jti). The
+// ids of issued tokens are tracked per user and per session, scored by their
+// expiry, so that all live tokens of a user or a session can be denied at once.
+// Without Redis nothing is tracked and access tokens live until they expire.
+type TokenRevocationService interface {
+	Track(claims *application_types.AccessToken)
+	IsRevoked(jti string) (bool, error)
+	RevokeToken(jti string, expiresAt time.Time)
+	RevokeSession(sessionID string)
+	RevokeUser(userID uint)
+}
+
+func NewTokenRevocationService() TokenRevocationService {
+	return &tokenRevocationService{}
+}
+
+func (svc *tokenRevocationService) Track(claims *application_types.AccessToken) {
+	if !db.IsRedisConfigured() || claims.ExpiresAt == nil {
+		return
+	}
+
+	ctx := context.Background()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	member := redis.Z{Score: float64(claims.ExpiresAt.Unix()), Member: claims.ID}
+
+	keys := []string{userAccessTokensKey(claims.UserID)}
+	if claims.SessionID != "" {
+		keys = append(keys, sessionAccessTokensKeyPrefix+claims.SessionID)
+	}
+
+	pipe := db.GetRedis().TxPipeline()
+	for _, key := range keys {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", now)
+		pipe.ZAdd(ctx, key, member)
+		// Long enough for impersonation tokens, which outlive regular access tokens.
+		pipe.Expire(ctx, key, models.ImpersonationTokenMaxTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.HighlightedDanger("Unable to track the access token. Message: " + err.Error())
+	}
+}
+
+func (svc *tokenRevocationService) IsRevoked(jti string) (bool, error) {
+	if !db.IsRedisConfigured() || jti == "" {
+		return false, nil
+	}
+
+	_, err := db.GetFromRedisCache(accessTokenDenylistKeyPrefix + jti)
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeToken denies the token until it expires on its own.
+func (svc *tokenRevocationService) RevokeToken(jti string, expiresAt time.Time) {
+	if !db.IsRedisConfigured() {
+		return
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return
+	}
+
+	if err := db.SetRedisCache(accessTokenDenylistKeyPrefix+jti, 1, ttl); err != nil {
+		logger.HighlightedDanger("Unable to deny the access token " + jti + ". Message: " + err.Error())
+	}
+}
+
+func (svc *tokenRevocationService) RevokeSession(sessionID string) {
+	logger.Info("Revoking access tokens of the session " + sessionID)
+	svc.revokeTracked(sessionAccessTokensKeyPrefix + sessionID)
+}
+
+func (svc *tokenRevocationService) RevokeUser(userID uint) {
+	logger.Info("Revoking access tokens of the user id " + strconv.FormatUint(uint64(userID), 10))
+	svc.revokeTracked(userAccessTokensKey(userID))
+}
+
+func (svc *tokenRevocationService) revokeTracked(key string) {
+	if !db.IsRedisConfigured() {
+		return
+	}
+
+	ctx := context.Background()
+	tokens, err := db.GetRedis().ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		logger.HighlightedDanger("Unable to read tracked access tokens. Message: " + err.Error())
+		return
+	}
+
+	for _, token := range tokens {
+		jti, ok := token.Member.(string)
+		if !ok {
+			continue
+		}
+		svc.RevokeToken(jti, time.Unix(int64(token.Score), 0))
+	}
+
+	if err := db.DeleteFromRedisCache(key); err != nil {
+		logger.Warning("Unable to delete tracked access tokens. Message: " + err.Error())
+	}
+}
+
+func userAccessTokensKey(userID uint) string {
+	return userAccessTokensKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
@@ -4,57 +4,139 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 	"treeforms_billing/application_types"
 	"treeforms_billing/db"
 	"treeforms_billing/dtos"
 	"treeforms_billing/logger"
+	"treeforms_billing/mailer"
 	"treeforms_billing/models"
 
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 type userService struct {
-	db *gorm.DB
+	revocationSvc TokenRevocationService
+	sessSvc       SessionService
+	roleSvc       RoleService
+	auditSvc      AuditEventService
+	// emailVerificationSvc confirms changed emails.
+	emailVerificationSvc EmailVerificationService
+	db                   *gorm.DB
+	// organizationID limits every query to the organization, see ForOrganization.
+	organizationID uint
 }
 
 type UserService interface {
-	Create(user *dtos.UserDTO) (*models.User, *application_types.ApplicationError)
+	ForOrganization(organizationID uint) UserService
+	Create(user *dtos.UserDTO, actor dtos.Actor) (*models.User, *application_types.ApplicationError)
 	Find(filter models.UserFilter) ([]*models.User, *application_types.ApplicationError)
 	FindByID(id uint) (*models.User, *application_types.ApplicationError)
-	UpdateByID(id uint, updatedUserData *dtos.UserDTO) (*models.User, *application_types.ApplicationError)
-	DeleteByID(id uint) *application_types.ApplicationError
+	UpdateByID(id uint, updatedUserData *dtos.UserDTO, actor dtos.Actor) (*models.User, *application_types.ApplicationError)
+	DeleteByID(id uint, actor dtos.Actor) *application_types.ApplicationError
 	FindByEmail(email string) (*models.User, *application_types.ApplicationError)
+	IsActive(id uint) (bool, error)
 	FindByPhone(phone string) (*models.User, *application_types.ApplicationError)
 }
 
 func NewUserService() UserService {
-	return &userService{
-		db: db.Get(),
+	svc := &userService{
+		revocationSvc: NewTokenRevocationService(),
+		sessSvc:       NewSessionService(),
+		roleSvc:       NewRoleService(),
+		auditSvc:      NewAuditEventService(),
+		db:            db.Get(),
 	}
+	// The verification service looks users up through this service, so it is
+	// wired here instead of calling NewEmailVerificationService.
+	svc.emailVerificationSvc = &emailVerificationService{userSvc: svc, mailer: mailer.New(), db: svc.db}
+	return svc
 }
 
-func (svc *userService) Create(userDTO *dtos.UserDTO) (*models.User, *application_types.ApplicationError) {
+// ForOrganization returns the service limited to the members of the
+// organization, who are returned with their role in it. The zero organization,
+// used by platform administrators and for logins, sees every user.
+func (svc *userService) ForOrganization(organizationID uint) UserService {
+	scoped := *svc
+	scoped.organizationID = organizationID
+	return &scoped
+}
+
+func (svc *userService) scoped() *gorm.DB {
+	return svc.db.Scopes(models.ScopeOrganizationMembers("id", svc.organizationID))
+}
+
+// withMemberRoles replaces the roles of the users by their roles in the
+// organization of the service.
+func (svc *userService) withMemberRoles(users ...*models.User) error {
+	if svc.organizationID == 0 || len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	var members []*models.OrganizationMember
+	if err := svc.db.Where("organization_id = ? AND user_id IN ?", svc.organizationID, ids).Find(&members).Error; err != nil {
+		return err
+	}
+
+	byUserID := make(map[uint]*models.OrganizationMember, len(members))
+	for _, member := range members {
+		byUserID[member.UserID] = member
+	}
+	for _, user := range users {
+		*user = *user.InOrganization(byUserID[user.ID])
+	}
+	return nil
+}
+
+func (svc *userService) Create(userDTO *dtos.UserDTO, actor dtos.Actor) (*models.User, *application_types.ApplicationError) {
 	logger.Info("Creating a new user.")
 	// Data transfering from DTO to model
 	user := &models.User{Name: userDTO.Name, Email: userDTO.Email, Phone: userDTO.Phone,
-		Role: userDTO.Role, Status: userDTO.Status}
+		Role: userDTO.Role, Status: userDTO.Status, OrganizationID: userDTO.OrganizationID}
+	if svc.organizationID != 0 {
+		user.OrganizationID = &svc.organizationID
+	}
+	organizationID := user.OrganizationIDOrZero()
 
 	// Validation checks
 	logger.Info("Validating new user fields.")
 	err := user.ValidateFields()
 	if err != nil {
 		appErr := application_types.NewApplicationError(false, http.StatusBadRequest, "Invalid request",
-			fmt.Errorf("Validation failed for creating the user. Message: "+err.Error()))
+			fmt.Errorf("Validation failed for creating the user. Message: %s", err.Error()))
 		logger.Warning(appErr.GetErrorMessage())
 		return nil, appErr
 	}
 
+	logger.Info("Checking given role exists")
+	if _, appErr := svc.roleSvc.ForOrganization(organizationID).FindByName(user.Role); appErr != nil {
+		return nil, appErr
+	}
+
+	if organizationID != 0 {
+		logger.Info("Checking given organization exists")
+		if err := svc.db.First(&models.Organization{}, *user.OrganizationID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warning("Given organization does not exist")
+			return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User creation failed", fmt.Errorf("Organization not found"))
+		} else if err != nil {
+			logger.Danger("Unable to find the organization. Gorm Message: " + err.Error())
+			return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User creation failed", err)
+		}
+	}
+
+	// Emails and phones identify the user at login, so they are unique across organizations.
 	logger.Info("Checking given email is enrolled by any other user")
 	if err := svc.db.Where("email =?", user.Email).First(&models.User{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Danger("Error occured while checking the user email enrolled by any other user")
-		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by email. Message: "+err.Error()))
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by email. Message: %s", err.Error()))
 	} else if err == nil {
 		logger.Warning("Given email id already in use")
 		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Email ID is already registered with another user"))
@@ -63,20 +145,42 @@ func (svc *userService) Create(userDTO *dtos.UserDTO) (*models.User, *applicatio
 	logger.Info("Checking given phone is enrolled by any other user")
 	if err := svc.db.Where("phone =?", user.Phone).First(&models.User{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Danger("Error occured while checking the user phone enrolled by any other user")
-		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by phone. Message: "+err.Error()))
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by phone. Message: %s", err.Error()))
 	} else if err == nil {
 		logger.Warning("Given phone already in use")
 		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Phone number is already registered with another user"))
 	}
 
-	// Creating the user
-	tx := svc.db.Create(&user)
-	if tx.Error != nil {
+	// Creating the user. Users of an organization hold the given role as
+	// members of it, their own role only applies outside of organizations.
+	var member *models.OrganizationMember
+	err = svc.db.Transaction(func(tx *gorm.DB) error {
+		if organizationID == 0 {
+			return tx.Create(user).Error
+		}
+
+		member = &models.OrganizationMember{OrganizationID: organizationID, Role: user.Role}
+		user.Role = models.RoleUser
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		member.UserID = user.ID
+		return addOrganizationMember(tx, member)
+	})
+	if err != nil {
 		appErr := application_types.NewApplicationError(false, http.StatusInternalServerError, "User creation failed",
-			fmt.Errorf("User creation failed. Message: "+err.Error()))
+			fmt.Errorf("User creation failed. Message: %w", err))
 		logger.Danger(appErr.GetErrorMessage())
 		return nil, appErr
 	}
+	if member != nil {
+		user = user.InOrganization(member)
+	}
+
+	event := newAuditEvent(models.AuditActionUserCreated, models.AuditOutcomeSuccess, actor)
+	event.TargetType, event.TargetID = models.AuditTargetUser, &user.ID
+	event.Changes = userAuditChanges(&models.User{}, user)
+	svc.auditSvc.Record(event)
 
 	logger.Success("User created succesfully.")
 	return user, nil
@@ -85,7 +189,7 @@ func (svc *userService) Create(userDTO *dtos.UserDTO) (*models.User, *applicatio
 func (svc *userService) Find(filter models.UserFilter) ([]*models.User, *application_types.ApplicationError) {
 	logger.Info("Finding users")
 	var users []*models.User
-	query := svc.db
+	query := svc.scoped()
 
 	if strings.TrimSpace(filter.Name) != "" {
 		logger.Info("Added Name filter to the user find query")
@@ -106,7 +210,12 @@ func (svc *userService) Find(filter models.UserFilter) ([]*models.User, *applica
 
 	if strings.TrimSpace(filter.Role) != "" {
 		logger.Info("Added Role filter to the user find query")
-		query = query.Where("role LIKE ?", "%"+strings.TrimSpace(filter.Role)+"%")
+		if svc.organizationID != 0 {
+			query = query.Where("id IN (?)", svc.db.Model(&models.OrganizationMember{}).Select("user_id").
+				Where("organization_id = ? AND role LIKE ?", svc.organizationID, "%"+strings.TrimSpace(filter.Role)+"%"))
+		} else {
+			query = query.Where("role LIKE ?", "%"+strings.TrimSpace(filter.Role)+"%")
+		}
 
 	}
 
@@ -119,7 +228,11 @@ func (svc *userService) Find(filter models.UserFilter) ([]*models.User, *applica
 	if err := query.Find(&users).Error; err != nil {
 		logger.Danger("Unable to find users. Message: " + err.Error())
 		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User find failed!",
-			fmt.Errorf("Unable to find users. Message: "+err.Error()))
+			fmt.Errorf("Unable to find users. Message: %s", err.Error()))
+	}
+	if err := svc.withMemberRoles(users...); err != nil {
+		logger.Danger("Unable to find the member roles of the users. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User find failed!", err)
 	}
 
 	logger.Success("Users found successfully")
@@ -129,7 +242,7 @@ func (svc *userService) Find(filter models.UserFilter) ([]*models.User, *applica
 func (svc *userService) FindByID(id uint) (*models.User, *application_types.ApplicationError) {
 	user := &models.User{}
 
-	if err := svc.db.First(user, id).Error; err != nil {
+	if err := svc.scoped().First(user, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			logger.Danger("No user found for the id " + strconv.FormatUint(uint64(id), 10))
 			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No user found for the given id", err)
@@ -137,20 +250,27 @@ func (svc *userService) FindByID(id uint) (*models.User, *application_types.Appl
 		}
 		logger.Danger("Unable to find user by id. Message: " + err.Error())
 		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find user with id",
-			fmt.Errorf("Unable to find user by id. Message: "+err.Error()))
+			fmt.Errorf("Unable to find user by id. Message: %s", err.Error()))
+	}
+	if err := svc.withMemberRoles(user); err != nil {
+		logger.Danger("Unable to find the member role of the user. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "Unable to find user with id", err)
 	}
 
 	logger.Success("User found by id!")
 	return user, nil
 }
 
-func (svc *userService) UpdateByID(id uint, updatedUserData *dtos.UserDTO) (*models.User, *application_types.ApplicationError) {
+func (svc *userService) UpdateByID(id uint, updatedUserData *dtos.UserDTO, actor dtos.Actor) (*models.User, *application_types.ApplicationError) {
 	logger.Info("Started updated user by id " + strconv.FormatUint(uint64(id), 10))
 	updatedUser, appErr := svc.FindByID(id)
 	if appErr != nil {
 		return nil, appErr
 	}
 
+	previous := *updatedUser
+	previousRole, previousStatus := updatedUser.Role, updatedUser.Status
+
 	if strings.TrimSpace(updatedUserData.Name) != "" {
 		logger.Info("User name changed")
 		updatedUser.Name = updatedUserData.Name
@@ -160,6 +280,11 @@ func (svc *userService) UpdateByID(id uint, updatedUserData *dtos.UserDTO) (*mod
 		logger.Info("User email changed")
 		updatedUser.Email = updatedUserData.Email
 	}
+	emailChanged := updatedUser.Email != previous.Email
+	if emailChanged {
+		// Nobody has confirmed the new address yet.
+		updatedUser.EmailVerifiedAt = nil
+	}
 
 	if strings.TrimSpace(updatedUserData.Phone) != "" {
 		logger.Info("User phone changed")
@@ -178,14 +303,37 @@ func (svc *userService) UpdateByID(id uint, updatedUserData *dtos.UserDTO) (*mod
 
 	if err := updatedUser.ValidateFields(); err != nil {
 		appErr = application_types.NewApplicationError(false, http.StatusBadRequest, "User update failed",
-			fmt.Errorf("Validation failed. Message: "+err.Error()))
+			fmt.Errorf("Validation failed. Message: %s", err.Error()))
 		logger.Danger(appErr.GetErrorMessage())
 		return nil, appErr
 	}
+
+	if svc.organizationID != 0 && (updatedUser.Name != previous.Name || updatedUser.Email != previous.Email ||
+		updatedUser.Phone != previous.Phone || updatedUser.Status != previous.Status) {
+		// The account is shared by the organizations of the user.
+		var otherMemberships int64
+		if err := svc.db.Model(&models.OrganizationMember{}).Where("user_id = ? AND organization_id <> ?", id, svc.organizationID).Count(&otherMemberships).Error; err != nil {
+			logger.Danger("Unable to count the organizations of the user. Message: " + err.Error())
+			return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User update failed", err)
+		}
+		if otherMemberships > 0 {
+			logger.Warning("User update stopped. Message: The user belongs to other organizations")
+			return nil, application_types.NewApplicationError(false, http.StatusForbidden, "Access denied",
+				fmt.Errorf("The user also belongs to other organizations, only their role can be changed"))
+		}
+	}
+
+	if updatedUser.Role != previousRole {
+		logger.Info("Checking given role exists")
+		if _, appErr := svc.roleSvc.ForOrganization(svc.organizationID).FindByName(updatedUser.Role); appErr != nil {
+			return nil, appErr
+		}
+	}
+
 	logger.Info("Checking given email is enrolled by any other user")
 	if err := svc.db.Where("email = ? AND id <> ?", updatedUser.Email, id).First(&models.User{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Danger("Error occured while checking the user email enrolled by any other user")
-		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by email. Message: "+err.Error()))
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by email. Message: %s", err.Error()))
 	} else if err == nil {
 		logger.Warning("Given email id already in use")
 		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Email ID is already registered with another user"))
@@ -194,24 +342,65 @@ func (svc *userService) UpdateByID(id uint, updatedUserData *dtos.UserDTO) (*mod
 	logger.Info("Checking given phone is enrolled by any other user")
 	if err := svc.db.Where("phone = ? AND id <> ?", updatedUser.Phone, id).First(&models.User{}).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Danger("Error occured while checking the user phone enrolled by any other user")
-		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by phone. Message: "+err.Error()))
+		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Unable to find user by phone. Message: %s", err.Error()))
 	} else if err == nil {
 		logger.Warning("Given phone already in use")
 		return nil, application_types.NewApplicationError(false, http.StatusUnprocessableEntity, "User update failed", fmt.Errorf("Phone number is already registered with another user"))
 	}
 
-	if err := svc.db.Save(updatedUser).Error; err != nil {
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if svc.organizationID == 0 {
+			return tx.Save(updatedUser).Error
+		}
+
+		// Within an organization the role is the one of the membership.
+		if err := tx.Omit("role", "organization_id").Save(updatedUser).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", svc.organizationID, id).
+			Update("role", updatedUser.Role).Error
+	})
+	if err != nil {
 		appErr = application_types.NewApplicationError(false, http.StatusInternalServerError, "User update failed.",
-			fmt.Errorf("Error occured while updating user. Message: "+err.Error()))
+			fmt.Errorf("Error occured while updating user. Message: %s", err.Error()))
 		logger.Danger(appErr.GetErrorMessage())
 		return nil, appErr
 	}
 
+	if updatedUser.Status != previousStatus {
+		forgetUserStatus(id)
+	}
+
+	// The email is changed at this point; a failed email can be resent by the user.
+	if emailChanged {
+		if appErr := svc.emailVerificationSvc.SendVerification(updatedUser); appErr != nil {
+			logger.Warning("Unable to send the verification email after the email change. Message: " + appErr.GetErrorMessage())
+		}
+	}
+
+	if updatedUser.Status != previousStatus && !updatedUser.IsActive() {
+		// Deactivation ends every session, which also denies the access tokens.
+		if appErr := svc.sessSvc.RevokeAll(id); appErr != nil {
+			logger.Danger("Unable to revoke the sessions of the deactivated user")
+			return nil, appErr
+		}
+	} else if updatedUser.Role != previousRole {
+		// Access tokens carry the role.
+		svc.revocationSvc.RevokeUser(id)
+	}
+
+	if changes := userAuditChanges(&previous, updatedUser); len(changes) > 0 {
+		event := newAuditEvent(models.AuditActionUserUpdated, models.AuditOutcomeSuccess, actor)
+		event.TargetType, event.TargetID = models.AuditTargetUser, &updatedUser.ID
+		event.Changes = changes
+		svc.auditSvc.Record(event)
+	}
+
 	logger.Success("User updated by id " + strconv.FormatUint(uint64(id), 10))
 	return updatedUser, nil
 }
 
-func (svc *userService) DeleteByID(id uint) *application_types.ApplicationError {
+func (svc *userService) DeleteByID(id uint, actor dtos.Actor) *application_types.ApplicationError {
 	logger.Info("Deleting a user with id " + strconv.FormatUint(uint64(id), 10))
 
 	user, appErr := svc.FindByID(id)
@@ -219,13 +408,70 @@ func (svc *userService) DeleteByID(id uint) *application_types.ApplicationError
 		return appErr
 	}
 
-	if err := svc.db.Delete(user).Error; err != nil {
+	if svc.organizationID != 0 {
+		if appErr := checkNotLastOwner(svc.db, svc.organizationID, id); appErr != nil {
+			return appErr
+		}
+	}
+
+	// Within an organization the user leaves it, and the account is only
+	// deleted with its last membership.
+	deleted := true
+	err := svc.db.Transaction(func(tx *gorm.DB) error {
+		if svc.organizationID != 0 {
+			if err := removeOrganizationMember(tx, svc.organizationID, id); err != nil {
+				return err
+			}
+			var memberships int64
+			if err := tx.Model(&models.OrganizationMember{}).Where("user_id = ?", id).Count(&memberships).Error; err != nil {
+				return err
+			}
+			deleted = memberships == 0
+		} else {
+			err := tx.Exec(`DELETE FROM organization_member_branches WHERE organization_member_id IN
+				(SELECT id FROM organization_members WHERE user_id = ?)`, id).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", id).Delete(&models.OrganizationMember{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if !deleted {
+			return nil
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
 		appErr = application_types.NewApplicationError(false, http.StatusInternalServerError, "User delete failed.",
-			fmt.Errorf("Unable to delete user if id"+strconv.FormatUint(uint64(id), 10)+". Message: "+err.Error()))
+			fmt.Errorf("Unable to delete user if id%s. Message: %s", strconv.FormatUint(uint64(id), 10), err.Error()))
 		logger.Danger(appErr.GetErrorMessage())
 		return appErr
 	}
 
+	if !deleted {
+		event := newAuditEvent(models.AuditActionMemberRemoved, models.AuditOutcomeSuccess, actor)
+		event.OrganizationID = &svc.organizationID
+		event.TargetType, event.TargetID = models.AuditTargetUser, &user.ID
+		event.Changes = map[string]models.AuditChange{"role": {From: user.Role, To: nil}}
+		svc.auditSvc.Record(event)
+
+		// The access tokens of the user may act in the organization.
+		svc.revocationSvc.RevokeUser(id)
+		logger.Success("Removed user with id " + strconv.FormatUint(uint64(id), 10) + " from the organization")
+		return nil
+	}
+
+	event := newAuditEvent(models.AuditActionUserDeleted, models.AuditOutcomeSuccess, actor)
+	event.TargetType, event.TargetID = models.AuditTargetUser, &user.ID
+	event.Details = "Deleted the user " + user.Email
+	svc.auditSvc.Record(event)
+
+	forgetUserStatus(id)
+	if appErr := svc.sessSvc.RevokeAll(id); appErr != nil {
+		logger.Warning("Unable to revoke the sessions of the deleted user. Message: " + appErr.GetErrorMessage())
+	}
 	logger.Success("Deleted user with id " + strconv.FormatUint(uint64(id), 10))
 	return nil
 }
@@ -234,14 +480,18 @@ func (svc *userService) FindByEmail(email string) (*models.User, *application_ty
 	logger.Info("Finding a user with email id " + email)
 
 	var user models.User
-	if err := svc.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := svc.scoped().Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			logger.Danger("Unable to find user by email.")
 			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No user found for the given email", err)
 		}
 		logger.Danger("Unable to find user by email. Message: " + err.Error())
 		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User find failed",
-			fmt.Errorf("Unable to find user by email. Message: "+err.Error()))
+			fmt.Errorf("Unable to find user by email. Message: %s", err.Error()))
+	}
+	if err := svc.withMemberRoles(&user); err != nil {
+		logger.Danger("Unable to find the member role of the user. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User find failed", err)
 	}
 
 	logger.Success("User found by email")
@@ -252,16 +502,96 @@ func (svc *userService) FindByPhone(phone string) (*models.User, *application_ty
 	logger.Info("Finding a user with phone " + phone)
 
 	var user models.User
-	if err := svc.db.Where("phone = ?", phone).First(user).Error; err != nil {
+	if err := svc.scoped().Where("phone = ?", phone).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			logger.Danger("Unable to find user by phone number.")
-			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No user found", fmt.Errorf("No user found for the given phone number"))
+			return nil, application_types.NewApplicationError(false, http.StatusNotFound, "No user found", fmt.Errorf("No user found for the given phone number: %w", err))
 		}
 		logger.Danger("Unable to find user by phone. Message: " + err.Error())
 		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User find failed",
-			fmt.Errorf("Unable to find user by phone. Message: "+err.Error()))
+			fmt.Errorf("Unable to find user by phone. Message: %s", err.Error()))
+	}
+	if err := svc.withMemberRoles(&user); err != nil {
+		logger.Danger("Unable to find the member role of the user. Message: " + err.Error())
+		return nil, application_types.NewApplicationError(false, http.StatusInternalServerError, "User find failed", err)
 	}
 
 	logger.Success("User found by phone")
 	return &user, nil
 }
+
+const (
+	userStatusKeyPrefix       = "user_status:"
+	defaultUserStatusCacheTTL = 30 * time.Second
+	userStatusDeleted         = "deleted"
+)
+
+// IsActive reports whether the user exists and is active. The status is cached
+// in Redis for USER_STATUS_CACHE_TTL (default 30s), so that it can be checked on
+// every request.
+func (svc *userService) IsActive(id uint) (bool, error) {
+	key := userStatusKeyPrefix + strconv.FormatUint(uint64(id), 10)
+	if status, err := db.GetFromRedisCache(key); err == nil {
+		return status == models.UserStatusActive, nil
+	} else if !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	var user models.User
+	status := userStatusDeleted
+	if err := svc.db.Select("status").First(&user, id).Error; err == nil {
+		status = user.Status
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	if err := db.SetRedisCache(key, status, userStatusCacheTTL()); err != nil {
+		logger.Warning("Unable to cache the user status. Message: " + err.Error())
+	}
+	return status == models.UserStatusActive, nil
+}
+
+func forgetUserStatus(id uint) {
+	if !db.IsRedisConfigured() {
+		return
+	}
+	if err := db.DeleteFromRedisCache(userStatusKeyPrefix + strconv.FormatUint(uint64(id), 10)); err != nil {
+		logger.Warning("Unable to forget the cached user status. Message: " + err.Error())
+	}
+}
+
+// userStatusCacheTTL returns 0 when USER_STATUS_CACHE_TTL disables the check.
+func userStatusCacheTTL() time.Duration {
+	value := os.Getenv("USER_STATUS_CACHE_TTL")
+	if value == "" {
+		return defaultUserStatusCacheTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return defaultUserStatusCacheTTL
+	}
+	return ttl
+}
+
+// IsUserStatusCheckEnabled reports whether the status of the user is checked on
+// every request. It needs Redis and can be disabled with USER_STATUS_CACHE_TTL=0.
+func IsUserStatusCheckEnabled() bool {
+	return db.IsRedisConfigured() && userStatusCacheTTL() > 0
+}
+
+// userAuditChanges returns the audited fields which differ between the users.
+func userAuditChanges(before, after *models.User) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for field, values := range map[string][2]string{
+		"name":   {before.Name, after.Name},
+		"email":  {before.Email, after.Email},
+		"phone":  {before.Phone, after.Phone},
+		"role":   {before.Role, after.Role},
+		"status": {before.Status, after.Status},
+	} {
+		if values[0] != values[1] {
+			changes[field] = models.AuditChange{From: values[0], To: values[1]}
+		}
+	}
+	return changes
+}
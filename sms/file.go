@@ -0,0 +1,51 @@
+package sms
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"treeforms_billing/logger"
+)
+
+type fileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSender returns a sender that appends every message as a line to
+// SMS_OUTBOX_FILE (default "outbox/sms.log"). Meant for local use.
+func NewFileSender() Sender {
+	path := os.Getenv("SMS_OUTBOX_FILE")
+	if path == "" {
+		path = filepath.Join("outbox", "sms.log")
+	}
+
+	return &fileSender{path: path}
+}
+
+func (s *fileSender) Send(phone, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		logger.HighlightedDanger("Unable to create sms outbox directory. Message: " + err.Error())
+		return fmt.Errorf("unable to create sms outbox directory: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		logger.HighlightedDanger("Unable to open sms outbox. Message: " + err.Error())
+		return fmt.Errorf("unable to open sms outbox: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, message); err != nil {
+		logger.HighlightedDanger("Unable to write sms to the outbox. Message: " + err.Error())
+		return fmt.Errorf("unable to write sms to the outbox: %w", err)
+	}
+
+	logger.Success("SMS for " + phone + " written to " + s.path)
+	return nil
+}
@@ -0,0 +1,15 @@
+package sms
+
+import "treeforms_billing/logger"
+
+type logSender struct{}
+
+// NewLogSender returns a sender that only logs the messages. Meant for local use.
+func NewLogSender() Sender {
+	return &logSender{}
+}
+
+func (s *logSender) Send(phone, message string) error {
+	logger.Info("SMS to " + phone + ": " + message)
+	return nil
+}
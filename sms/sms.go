@@ -0,0 +1,25 @@
+package sms
+
+import (
+	"os"
+	"strings"
+	"treeforms_billing/logger"
+)
+
+// Sender delivers a text message to a phone number.
+type Sender interface {
+	Send(phone, message string) error
+}
+
+// New returns the sender selected by SMS_DRIVER. "file" appends the messages to
+// SMS_OUTBOX_FILE, anything else writes them to the application log. A real
+// provider can be added as another Sender implementation.
+func New() Sender {
+	switch strings.ToLower(os.Getenv("SMS_DRIVER")) {
+	case "file":
+		return NewFileSender()
+	default:
+		logger.Info("Using log sms sender. Text messages will be written to the application log.")
+		return NewLogSender()
+	}
+}
@@ -0,0 +1,145 @@
+package sso
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"treeforms_billing/logger"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Provider is an OpenID Connect identity provider configured through
+// environment variables, where NAME is the upper cased provider name:
+//
+//	OIDC_PROVIDERS                 comma separated provider names, e.g. "google,keycloak"
+//	OIDC_<NAME>_ISSUER             issuer URL used for discovery
+//	OIDC_<NAME>_CLIENT_ID          client id registered at the provider
+//	OIDC_<NAME>_CLIENT_SECRET      client secret, empty for public clients
+//	OIDC_<NAME>_REDIRECT_URL       callback URL of this API for the provider
+//	OIDC_<NAME>_SCOPES             extra scopes besides "openid email profile"
+//	OIDC_<NAME>_ALLOWED_DOMAINS    comma separated email domains allowed to login
+//	OIDC_<NAME>_AUTO_PROVISION     "true" to create accounts for unknown users, off by default
+//	OIDC_<NAME>_ORGANIZATION_ID    organization of the provisioned users
+//
+// Auto provisioning requires ALLOWED_DOMAINS and ORGANIZATION_ID, so that a
+// public provider cannot be used to create accounts outside of an organization.
+//
+// Any issuer implementing discovery works, so a local mock IdP can be used by
+// pointing the issuer to it.
+type Provider struct {
+	Name           string
+	OAuth2         *oauth2.Config
+	Verifier       *oidc.IDTokenVerifier
+	AllowedDomains []string
+	AutoProvision  bool
+	// OrganizationID is zero when provisioned users join no organization.
+	OrganizationID uint
+}
+
+var (
+	mu          sync.Mutex
+	providers   = map[string]*Provider{}
+	namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// GetProvider returns the configured provider, running the discovery on first use.
+func GetProvider(ctx context.Context, name string) (*Provider, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !namePattern.MatchString(name) || !isEnabled(name) {
+		return nil, fmt.Errorf("unknown sso provider %q", name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if provider, ok := providers[name]; ok {
+		return provider, nil
+	}
+
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	issuer := os.Getenv(prefix + "ISSUER")
+	clientID := os.Getenv(prefix + "CLIENT_ID")
+	if issuer == "" || clientID == "" {
+		return nil, fmt.Errorf("sso provider %q is missing %sISSUER or %sCLIENT_ID", name, prefix, prefix)
+	}
+
+	discovered, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		logger.HighlightedDanger("OIDC discovery failed for the provider " + name + ". Message: " + err.Error())
+		return nil, fmt.Errorf("oidc discovery failed for %q: %w", name, err)
+	}
+
+	scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+	scopes = append(scopes, splitList(os.Getenv(prefix+"SCOPES"))...)
+
+	provider := &Provider{
+		Name: name,
+		OAuth2: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       scopes,
+		},
+		Verifier:       discovered.Verifier(&oidc.Config{ClientID: clientID}),
+		AllowedDomains: splitList(strings.ToLower(os.Getenv(prefix + "ALLOWED_DOMAINS"))),
+		AutoProvision:  strings.EqualFold(os.Getenv(prefix+"AUTO_PROVISION"), "true"),
+	}
+	if organizationID := os.Getenv(prefix + "ORGANIZATION_ID"); organizationID != "" {
+		id, err := strconv.ParseUint(organizationID, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("sso provider %q has an invalid %sORGANIZATION_ID: %w", name, prefix, err)
+		}
+		provider.OrganizationID = uint(id)
+	}
+	if provider.AutoProvision && (len(provider.AllowedDomains) == 0 || provider.OrganizationID == 0) {
+		return nil, fmt.Errorf("sso provider %q needs %sALLOWED_DOMAINS and %sORGANIZATION_ID for %sAUTO_PROVISION", name, prefix, prefix, prefix)
+	}
+
+	providers[name] = provider
+	logger.Success("OIDC provider " + name + " discovered at " + issuer)
+	return provider, nil
+}
+
+// IsEmailAllowed checks the email against OIDC_<NAME>_ALLOWED_DOMAINS.
+func (p *Provider) IsEmailAllowed(email string) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return false
+	}
+	for _, allowed := range p.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func isEnabled(name string) bool {
+	for _, enabled := range splitList(strings.ToLower(os.Getenv("OIDC_PROVIDERS"))) {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}